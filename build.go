@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"text/template"
+)
+
+// Build defines the Drone build metadata available to templates.
+type Build struct {
+	Repo        string
+	RepoOwner   string
+	RepoName    string
+	Branch      string
+	Commit      string
+	Tag         string
+	Event       string
+	BuildNumber int
+	DeployTo    string
+}
+
+// ShortCommit returns the abbreviated commit sha.
+func (b Build) ShortCommit() string {
+	if len(b.Commit) > 8 {
+		return b.Commit[:8]
+	}
+	return b.Commit
+}
+
+// render is a helper function that expands the Go template using the build
+// metadata, e.g. /builds/{{ .Branch }}/{{ .BuildNumber }}/
+func render(text string, build Build) (string, error) {
+	if !strings.Contains(text, "{{") {
+		return text, nil
+	}
+
+	tmpl, err := template.New("").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, build); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/drone-plugins/drone-s3/uploader"
+)
+
+// parseDestinations is a helper function that parses the destinations
+// provided as a JSON list of objects, e.g. [{"bucket": "mirror", "region":
+// "eu-west-1"}]
+func parseDestinations(value string) ([]uploader.Destination, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, nil
+	}
+
+	var destinations []uploader.Destination
+	if err := json.Unmarshal([]byte(value), &destinations); err != nil {
+		return nil, fmt.Errorf("invalid destinations: %s", err)
+	}
+	return destinations, nil
+}
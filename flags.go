@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/drone-plugins/drone-s3/uploader"
+)
+
+// StringMapFlag is a flag holding a map of strings, provided as a JSON object.
+// A plain string value is applied to all files using the "*" pattern.
+type StringMapFlag struct {
+	parts map[string]string
+}
+
+// String returns the string representation of the flag.
+func (s *StringMapFlag) String() string {
+	return fmt.Sprintf("%s", s.parts)
+}
+
+// Get returns the parsed map.
+func (s *StringMapFlag) Get() map[string]string {
+	return s.parts
+}
+
+// Set parses the flag value.
+func (s *StringMapFlag) Set(value string) error {
+	s.parts = map[string]string{}
+	if err := json.Unmarshal([]byte(value), &s.parts); err != nil {
+		s.parts = map[string]string{"*": value}
+	}
+	return nil
+}
+
+// DeepStringMapFlag is a flag holding maps of strings keyed by file pattern,
+// provided as a JSON object. A flat JSON object is applied to all files using
+// the "*" pattern.
+type DeepStringMapFlag struct {
+	parts map[string]map[string]string
+}
+
+// String returns the string representation of the flag.
+func (d *DeepStringMapFlag) String() string {
+	return fmt.Sprintf("%s", d.parts)
+}
+
+// Get returns the parsed maps.
+func (d *DeepStringMapFlag) Get() map[string]map[string]string {
+	return d.parts
+}
+
+// Set parses the flag value.
+func (d *DeepStringMapFlag) Set(value string) error {
+	d.parts = map[string]map[string]string{}
+	if err := json.Unmarshal([]byte(value), &d.parts); err == nil {
+		return nil
+	}
+
+	var flat map[string]string
+	if err := json.Unmarshal([]byte(value), &flat); err != nil {
+		return err
+	}
+	d.parts = map[string]map[string]string{"*": flat}
+	return nil
+}
+
+// PatternsFlag is a flag holding a list of Glob patterns, provided as a JSON
+// list or separated by commas. Commas inside braces are part of the pattern,
+// e.g. *.{js,css}
+type PatternsFlag struct {
+	parts uploader.Patterns
+}
+
+// String returns the string representation of the flag.
+func (p *PatternsFlag) String() string {
+	return fmt.Sprintf("%s", p.parts)
+}
+
+// Get returns the parsed patterns.
+func (p *PatternsFlag) Get() []string {
+	return p.parts
+}
+
+// Set parses the flag value, either a JSON list or a string decoded like the
+// patterns of a mapping.
+func (p *PatternsFlag) Set(value string) error {
+	p.parts = nil
+	if err := json.Unmarshal([]byte(value), &p.parts); err == nil {
+		return nil
+	}
+	quoted, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(quoted, &p.parts)
+}
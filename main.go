@@ -1,11 +1,14 @@
 package main
 
 import (
-	"log"
+	"context"
+	"fmt"
 	"os"
-	"strings"
+	"time"
 
+	log "github.com/Sirupsen/logrus"
 	"github.com/codegangsta/cli"
+	"github.com/drone-plugins/drone-s3/uploader"
 	_ "github.com/joho/godotenv/autoload"
 )
 
@@ -19,11 +22,17 @@ func main() {
 	app.Version = version
 	app.Flags = []cli.Flag{
 
-		cli.StringFlag{
+		cli.StringSliceFlag{
 			Name:   "endpoint",
-			Usage:  "endpoint for the s3 connection",
+			Usage:  "endpoint for the s3 connection, followed by the failover endpoints",
 			EnvVar: "PLUGIN_ENDPOINT",
 		},
+		cli.GenericFlag{
+			Name:   "endpoints",
+			Usage:  "custom endpoints of the sts and cloudfront services",
+			Value:  &StringMapFlag{},
+			EnvVar: "PLUGIN_ENDPOINTS",
+		},
 		cli.StringFlag{
 			Name:   "access-key",
 			Usage:  "aws access key",
@@ -34,12 +43,78 @@ func main() {
 			Usage:  "aws secret key",
 			EnvVar: "PLUGIN_SECRET_KEY,AWS_SECRET_ACCESS_KEY",
 		},
+		cli.BoolFlag{
+			Name:   "anonymous",
+			Usage:  "send unsigned requests without credentials",
+			EnvVar: "PLUGIN_ANONYMOUS",
+		},
+		cli.StringFlag{
+			Name:   "assume-role",
+			Usage:  "aws iam role to assume",
+			EnvVar: "PLUGIN_ASSUME_ROLE",
+		},
+		cli.StringFlag{
+			Name:   "external-id",
+			Usage:  "external id used when assuming the role",
+			EnvVar: "PLUGIN_EXTERNAL_ID",
+		},
+		cli.StringFlag{
+			Name:   "role-session-name",
+			Usage:  "session name used when assuming the role",
+			Value:  "drone-s3",
+			EnvVar: "PLUGIN_ROLE_SESSION_NAME",
+		},
+		cli.StringFlag{
+			Name:   "role-arn",
+			Usage:  "aws iam role assumed with the web identity token",
+			EnvVar: "PLUGIN_ROLE_ARN,AWS_ROLE_ARN",
+		},
+		cli.StringFlag{
+			Name:   "web-identity-token-file",
+			Usage:  "path to the web identity token file",
+			EnvVar: "PLUGIN_WEB_IDENTITY_TOKEN_FILE,AWS_WEB_IDENTITY_TOKEN_FILE",
+		},
 		cli.StringFlag{
 			Name:   "bucket",
 			Usage:  "aws bucket",
 			Value:  "us-east-1",
 			EnvVar: "PLUGIN_BUCKET",
 		},
+		cli.StringFlag{
+			Name:   "destinations",
+			Usage:  "list of additional buckets uploaded to",
+			EnvVar: "PLUGIN_DESTINATIONS",
+		},
+		cli.BoolFlag{
+			Name:   "requester-pays",
+			Usage:  "pay for the requests to a requester pays bucket",
+			EnvVar: "PLUGIN_REQUESTER_PAYS",
+		},
+		cli.BoolFlag{
+			Name:   "create-bucket",
+			Usage:  "create the bucket when missing",
+			EnvVar: "PLUGIN_CREATE_BUCKET",
+		},
+		cli.BoolTFlag{
+			Name:   "preflight",
+			Usage:  "check the bucket access before uploading",
+			EnvVar: "PLUGIN_PREFLIGHT",
+		},
+		cli.BoolFlag{
+			Name:   "preflight-probe",
+			Usage:  "check the write access with an empty object before uploading",
+			EnvVar: "PLUGIN_PREFLIGHT_PROBE",
+		},
+		cli.BoolFlag{
+			Name:   "require-encryption",
+			Usage:  "fail when the bucket has no default encryption",
+			EnvVar: "PLUGIN_REQUIRE_ENCRYPTION",
+		},
+		cli.StringFlag{
+			Name:   "bucket-acl",
+			Usage:  "canned acl of the created bucket",
+			EnvVar: "PLUGIN_BUCKET_ACL",
+		},
 		cli.StringFlag{
 			Name:   "region",
 			Usage:  "aws region",
@@ -48,20 +123,61 @@ func main() {
 		},
 		cli.StringFlag{
 			Name:   "acl",
-			Usage:  "upload files with acl",
+			Usage:  "upload files with acl (none to omit the acl)",
 			Value:  "private",
 			EnvVar: "PLUGIN_ACL",
 		},
 		cli.StringFlag{
+			Name:   "grant-read",
+			Usage:  "grantees allowed to read the files, replacing the acl",
+			EnvVar: "PLUGIN_GRANT_READ",
+		},
+		cli.StringFlag{
+			Name:   "grant-read-acp",
+			Usage:  "grantees allowed to read the acl of the files",
+			EnvVar: "PLUGIN_GRANT_READ_ACP",
+		},
+		cli.StringFlag{
+			Name:   "grant-write-acp",
+			Usage:  "grantees allowed to write the acl of the files",
+			EnvVar: "PLUGIN_GRANT_WRITE_ACP",
+		},
+		cli.StringFlag{
+			Name:   "grant-full-control",
+			Usage:  "grantees with full control of the files",
+			EnvVar: "PLUGIN_GRANT_FULL_CONTROL",
+		},
+		cli.GenericFlag{
 			Name:   "source",
 			Usage:  "upload files from source folder",
 			EnvVar: "PLUGIN_SOURCE",
+			Value:  &PatternsFlag{},
 		},
 		cli.StringFlag{
 			Name:   "target",
 			Usage:  "upload files to target folder",
 			EnvVar: "PLUGIN_TARGET",
 		},
+		cli.StringFlag{
+			Name:   "mappings",
+			Usage:  "list of source to target mappings",
+			EnvVar: "PLUGIN_MAPPINGS",
+		},
+		cli.StringFlag{
+			Name:   "strip-prefix",
+			Usage:  "strip the prefix from the source path",
+			EnvVar: "PLUGIN_STRIP_PREFIX",
+		},
+		cli.StringFlag{
+			Name:   "rewrites",
+			Usage:  "list of regular expression rules renaming the object keys",
+			EnvVar: "PLUGIN_REWRITES",
+		},
+		cli.BoolTFlag{
+			Name:   "strip-leading-slash",
+			Usage:  "upload to keys without a leading slash",
+			EnvVar: "PLUGIN_STRIP_LEADING_SLASH",
+		},
 		cli.BoolFlag{
 			Name:   "recursive",
 			Usage:  "upload files recursively",
@@ -72,11 +188,175 @@ func main() {
 			Usage:  "ignore files matching exclude pattern",
 			EnvVar: "PLUGIN_EXCLUDE",
 		},
+		cli.BoolFlag{
+			Name:   "use-gitignore",
+			Usage:  "exclude files ignored by git",
+			EnvVar: "PLUGIN_USE_GITIGNORE",
+		},
+		cli.BoolTFlag{
+			Name:   "include-hidden",
+			Usage:  "match hidden files and folders",
+			EnvVar: "PLUGIN_INCLUDE_HIDDEN",
+		},
+		cli.StringFlag{
+			Name:   "min-size",
+			Usage:  "minimum size of the uploaded files (e.g. 1B)",
+			EnvVar: "PLUGIN_MIN_SIZE",
+		},
+		cli.StringFlag{
+			Name:   "max-size",
+			Usage:  "maximum size of the uploaded files (e.g. 1GB)",
+			EnvVar: "PLUGIN_MAX_SIZE",
+		},
+		cli.StringFlag{
+			Name:   "max-total-size",
+			Usage:  "maximum total size of the matched files (e.g. 500MB)",
+			EnvVar: "PLUGIN_MAX_TOTAL_SIZE",
+		},
+		cli.IntFlag{
+			Name:   "max-files",
+			Usage:  "maximum number of matched files",
+			EnvVar: "PLUGIN_MAX_FILES",
+		},
+		cli.StringFlag{
+			Name:   "symlinks",
+			Usage:  "symlink handling (follow, skip or error)",
+			EnvVar: "PLUGIN_SYMLINKS",
+			Value:  "follow",
+		},
+		cli.BoolTFlag{
+			Name:   "fail-on-empty-source",
+			Usage:  "fail when no files match the source",
+			EnvVar: "PLUGIN_FAIL_ON_EMPTY_SOURCE",
+		},
+		cli.StringFlag{
+			Name:   "log-format",
+			Usage:  "log format (text or json)",
+			Value:  "text",
+			EnvVar: "PLUGIN_LOG_FORMAT",
+		},
+		cli.StringFlag{
+			Name:   "log-level",
+			Usage:  "log level (debug, info, warn or error)",
+			Value:  "info",
+			EnvVar: "PLUGIN_LOG_LEVEL",
+		},
 		cli.BoolFlag{
 			Name:   "dry-run",
 			Usage:  "dry run for debug purposes",
 			EnvVar: "PLUGIN_DRY_RUN",
 		},
+		cli.StringFlag{
+			Name:   "dry-run-report",
+			Usage:  "write the dry run report to a json file",
+			EnvVar: "PLUGIN_DRY_RUN_REPORT",
+		},
+		cli.StringFlag{
+			Name:   "manifest",
+			Usage:  "write a manifest of the uploaded files to a json file",
+			EnvVar: "PLUGIN_MANIFEST",
+		},
+		cli.BoolFlag{
+			Name:   "public-urls",
+			Usage:  "print the public urls of the uploaded files",
+			EnvVar: "PLUGIN_PUBLIC_URLS",
+		},
+		cli.BoolFlag{
+			Name:   "presign",
+			Usage:  "presign the download urls of the uploaded files",
+			EnvVar: "PLUGIN_PRESIGN",
+		},
+		cli.DurationFlag{
+			Name:   "presign-expires",
+			Usage:  "validity of the presigned urls",
+			Value:  24 * time.Hour,
+			EnvVar: "PLUGIN_PRESIGN_EXPIRES",
+		},
+		cli.DurationFlag{
+			Name:   "timeout",
+			Usage:  "timeout of the whole run",
+			EnvVar: "PLUGIN_TIMEOUT",
+		},
+		cli.DurationFlag{
+			Name:   "file-timeout",
+			Usage:  "timeout of the upload of a single file",
+			EnvVar: "PLUGIN_FILE_TIMEOUT",
+		},
+		cli.DurationFlag{
+			Name:   "connect-timeout",
+			Usage:  "timeout of establishing a connection",
+			Value:  30 * time.Second,
+			EnvVar: "PLUGIN_CONNECT_TIMEOUT",
+		},
+		cli.DurationFlag{
+			Name:   "response-header-timeout",
+			Usage:  "timeout of waiting for the response headers after sending a request",
+			EnvVar: "PLUGIN_RESPONSE_HEADER_TIMEOUT",
+		},
+		cli.IntFlag{
+			Name:   "max-idle-conns-per-host",
+			Usage:  "number of idle connections kept per host",
+			EnvVar: "PLUGIN_MAX_IDLE_CONNS_PER_HOST",
+		},
+		cli.IntFlag{
+			Name:   "max-retries",
+			Usage:  "maximum number of retries for failed requests",
+			Value:  3,
+			EnvVar: "PLUGIN_MAX_RETRIES",
+		},
+		cli.DurationFlag{
+			Name:   "retry-base-delay",
+			Usage:  "base delay of the exponential retry backoff",
+			Value:  30 * time.Millisecond,
+			EnvVar: "PLUGIN_RETRY_BASE_DELAY",
+		},
+		cli.DurationFlag{
+			Name:   "retry-max-delay",
+			Usage:  "maximum delay of the exponential retry backoff",
+			Value:  5 * time.Minute,
+			EnvVar: "PLUGIN_RETRY_MAX_DELAY",
+		},
+		cli.StringFlag{
+			Name:   "retry-mode",
+			Usage:  "retry mode (standard or adaptive)",
+			Value:  "standard",
+			EnvVar: "PLUGIN_RETRY_MODE",
+		},
+		cli.IntFlag{
+			Name:   "retries-per-file",
+			Usage:  "number of times an interrupted file upload is attempted again",
+			EnvVar: "PLUGIN_RETRIES_PER_FILE",
+		},
+		cli.BoolFlag{
+			Name:   "use-dualstack",
+			Usage:  "use the dual-stack (ipv6) endpoints",
+			EnvVar: "PLUGIN_USE_DUALSTACK",
+		},
+		cli.BoolFlag{
+			Name:   "use-fips",
+			Usage:  "use the fips endpoints",
+			EnvVar: "PLUGIN_USE_FIPS",
+		},
+		cli.StringFlag{
+			Name:   "ca-cert",
+			Usage:  "pem encoded certificates of private cas",
+			EnvVar: "PLUGIN_CA_CERT",
+		},
+		cli.StringFlag{
+			Name:   "ca-cert-path",
+			Usage:  "path to pem encoded certificates of private cas",
+			EnvVar: "PLUGIN_CA_CERT_PATH",
+		},
+		cli.BoolFlag{
+			Name:   "insecure-skip-verify",
+			Usage:  "skip the tls certificate verification (insecure)",
+			EnvVar: "PLUGIN_INSECURE_SKIP_VERIFY",
+		},
+		cli.StringFlag{
+			Name:   "proxy",
+			Usage:  "proxy url used for all requests",
+			EnvVar: "PLUGIN_PROXY",
+		},
 		cli.BoolFlag{
 			Name:   "path-style",
 			Usage:  "use path style for bucket paths",
@@ -87,6 +367,384 @@ func main() {
 			Usage:  "prior to upload, compress files and use gzip content-encoding",
 			EnvVar: "PLUGIN_COMPRESS",
 		},
+		cli.StringFlag{
+			Name:   "encoding",
+			Usage:  "compression encoding (gzip or br)",
+			Value:  "gzip",
+			EnvVar: "PLUGIN_ENCODING",
+		},
+		cli.StringFlag{
+			Name:   "compress-disk-threshold",
+			Usage:  "compress files larger than this size into a temp file (e.g. 64MB)",
+			EnvVar: "PLUGIN_COMPRESS_DISK_THRESHOLD",
+		},
+		cli.GenericFlag{
+			Name:   "content-type",
+			Usage:  "content-type header values keyed by file pattern",
+			Value:  &StringMapFlag{},
+			EnvVar: "PLUGIN_CONTENT_TYPE",
+		},
+		cli.BoolTFlag{
+			Name:   "charset",
+			Usage:  "append the utf-8 charset to text content types",
+			EnvVar: "PLUGIN_CHARSET",
+		},
+		cli.GenericFlag{
+			Name:   "cache-control",
+			Usage:  "cache-control header values keyed by file pattern",
+			Value:  &StringMapFlag{},
+			EnvVar: "PLUGIN_CACHE_CONTROL",
+		},
+		cli.StringFlag{
+			Name:   "expires",
+			Usage:  "expires header, as a time or a duration from now (e.g. 2030-01-01T00:00:00Z or 30d)",
+			EnvVar: "PLUGIN_EXPIRES",
+		},
+		cli.StringFlag{
+			Name:   "object-lock-mode",
+			Usage:  "object lock retention mode (GOVERNANCE or COMPLIANCE)",
+			EnvVar: "PLUGIN_OBJECT_LOCK_MODE",
+		},
+		cli.StringFlag{
+			Name:   "object-lock-retain-until",
+			Usage:  "object lock retention, as a time or a duration from now (e.g. 2030-01-01T00:00:00Z or 365d)",
+			EnvVar: "PLUGIN_OBJECT_LOCK_RETAIN_UNTIL",
+		},
+		cli.GenericFlag{
+			Name:   "storage-class",
+			Usage:  "storage class, optionally keyed by file pattern",
+			Value:  &StringMapFlag{},
+			EnvVar: "PLUGIN_STORAGE_CLASS",
+		},
+		cli.GenericFlag{
+			Name:   "metadata",
+			Usage:  "object metadata, optionally keyed by file pattern",
+			Value:  &DeepStringMapFlag{},
+			EnvVar: "PLUGIN_METADATA",
+		},
+		cli.BoolFlag{
+			Name:   "preserve-attributes",
+			Usage:  "store the file mtime and mode as object metadata",
+			EnvVar: "PLUGIN_PRESERVE_ATTRIBUTES",
+		},
+		cli.StringFlag{
+			Name:   "tags",
+			Usage:  "object tags (e.g. project=web,env=staging)",
+			EnvVar: "PLUGIN_TAGS",
+		},
+		cli.StringFlag{
+			Name:   "expire-after",
+			Usage:  "days after which the files expire, applied as the ttl tag (e.g. 30d)",
+			EnvVar: "PLUGIN_EXPIRE_AFTER",
+		},
+		cli.BoolFlag{
+			Name:   "expire-lifecycle-rule",
+			Usage:  "add the bucket lifecycle rule expiring the files tagged with the ttl",
+			EnvVar: "PLUGIN_EXPIRE_LIFECYCLE_RULE",
+		},
+		cli.GenericFlag{
+			Name:   "redirects",
+			Usage:  "website redirect locations keyed by object key",
+			Value:  &StringMapFlag{},
+			EnvVar: "PLUGIN_REDIRECTS",
+		},
+		cli.GenericFlag{
+			Name:   "content-encoding",
+			Usage:  "content-encoding of pre-compressed files keyed by file pattern",
+			Value:  &StringMapFlag{},
+			EnvVar: "PLUGIN_CONTENT_ENCODING",
+		},
+		cli.BoolFlag{
+			Name:   "website",
+			Usage:  "apply the defaults of static websites",
+			EnvVar: "PLUGIN_WEBSITE",
+		},
+		cli.StringSliceFlag{
+			Name:   "upload-last",
+			Usage:  "file patterns of entry points uploaded after all other files",
+			EnvVar: "PLUGIN_UPLOAD_LAST",
+		},
+		cli.StringSliceFlag{
+			Name:   "fingerprint",
+			Usage:  "file patterns of assets renamed with a content hash",
+			EnvVar: "PLUGIN_FINGERPRINT",
+		},
+		cli.StringFlag{
+			Name:   "fingerprint-manifest",
+			Usage:  "write the renamed assets to a json file",
+			EnvVar: "PLUGIN_FINGERPRINT_MANIFEST",
+		},
+		cli.StringFlag{
+			Name:   "integrity",
+			Usage:  "hash algorithm of the integrity hashes of scripts and stylesheets",
+			EnvVar: "PLUGIN_INTEGRITY",
+		},
+		cli.BoolFlag{
+			Name:   "sitemap",
+			Usage:  "upload a sitemap of the uploaded pages in website mode",
+			EnvVar: "PLUGIN_SITEMAP",
+		},
+		cli.StringFlag{
+			Name:   "base-url",
+			Usage:  "base url of the site linked by the sitemap",
+			EnvVar: "PLUGIN_BASE_URL",
+		},
+		cli.BoolFlag{
+			Name:   "generate-index",
+			Usage:  "upload index pages listing the uploaded directories",
+			EnvVar: "PLUGIN_GENERATE_INDEX",
+		},
+		cli.BoolFlag{
+			Name:   "create-folder-markers",
+			Usage:  "upload empty folder marker objects for the uploaded directories",
+			EnvVar: "PLUGIN_CREATE_FOLDER_MARKERS",
+		},
+		cli.BoolFlag{
+			Name:   "only-changed",
+			Usage:  "skip files with the same content as the remote object",
+			EnvVar: "PLUGIN_ONLY_CHANGED",
+		},
+		cli.StringFlag{
+			Name:   "overwrite",
+			Usage:  "overwrite existing objects always, never, if-newer or if-different",
+			EnvVar: "PLUGIN_OVERWRITE",
+		},
+		cli.BoolFlag{
+			Name:   "immutable",
+			Usage:  "fail instead of replacing existing objects using conditional writes",
+			EnvVar: "PLUGIN_IMMUTABLE",
+		},
+		cli.BoolFlag{
+			Name:   "atomic",
+			Usage:  "upload to a staging area and copy to the target once all files are uploaded",
+			EnvVar: "PLUGIN_ATOMIC",
+		},
+		cli.BoolFlag{
+			Name:   "rollback",
+			Usage:  "delete the uploaded files when an upload fails",
+			EnvVar: "PLUGIN_ROLLBACK",
+		},
+		cli.BoolFlag{
+			Name:   "continue-on-error",
+			Usage:  "upload all files when uploads fail, failing at the end",
+			EnvVar: "PLUGIN_CONTINUE_ON_ERROR",
+		},
+		cli.StringFlag{
+			Name:   "state-file",
+			Usage:  "file recording the uploaded files to skip unchanged files",
+			EnvVar: "PLUGIN_STATE_FILE",
+		},
+		cli.BoolFlag{
+			Name:   "remote-manifest",
+			Usage:  "record the uploaded files in a manifest object of the target",
+			EnvVar: "PLUGIN_REMOTE_MANIFEST",
+		},
+		cli.BoolFlag{
+			Name:   "changed-only",
+			Usage:  "only upload the files changed by the pushed commits",
+			EnvVar: "PLUGIN_CHANGED_ONLY",
+		},
+		cli.BoolFlag{
+			Name:   "sync",
+			Usage:  "delete remote files under the target that no longer exist locally",
+			EnvVar: "PLUGIN_SYNC,PLUGIN_DELETE",
+		},
+		cli.IntFlag{
+			Name:   "retain-builds",
+			Usage:  "number of builds kept next to the target",
+			EnvVar: "PLUGIN_RETAIN_BUILDS",
+		},
+		cli.IntFlag{
+			Name:   "retain-days",
+			Usage:  "number of days builds are kept next to the target",
+			EnvVar: "PLUGIN_RETAIN_DAYS",
+		},
+		cli.IntFlag{
+			Name:   "max-delete",
+			Usage:  "maximum number of remote files deleted when syncing",
+			EnvVar: "PLUGIN_MAX_DELETE",
+		},
+		cli.StringFlag{
+			Name:   "cloudfront-distribution",
+			Usage:  "cloudfront distribution id to invalidate after uploading",
+			EnvVar: "PLUGIN_CLOUDFRONT_DISTRIBUTION_ID,PLUGIN_CLOUDFRONT_DISTRIBUTION",
+		},
+		cli.StringSliceFlag{
+			Name:   "invalidation-paths",
+			Usage:  "cloudfront paths to invalidate",
+			EnvVar: "PLUGIN_INVALIDATION_PATHS",
+		},
+		cli.StringFlag{
+			Name:   "website-config",
+			Usage:  "website configuration applied to the bucket",
+			EnvVar: "PLUGIN_WEBSITE_CONFIG",
+		},
+		cli.StringFlag{
+			Name:   "cors",
+			Usage:  "cors rules applied to the bucket",
+			EnvVar: "PLUGIN_CORS",
+		},
+		cli.BoolFlag{
+			Name:   "download",
+			Usage:  "download files matching source from the bucket into the target folder",
+			EnvVar: "PLUGIN_DOWNLOAD",
+		},
+		cli.BoolFlag{
+			Name:   "copy",
+			Usage:  "copy objects matching source from the source bucket to the target with server-side copies",
+			EnvVar: "PLUGIN_COPY",
+		},
+		cli.BoolFlag{
+			Name:   "move",
+			Usage:  "move objects matching source from the source bucket to the target, deleting them once copied",
+			EnvVar: "PLUGIN_MOVE",
+		},
+		cli.BoolFlag{
+			Name:   "restore",
+			Usage:  "request the restore of archived objects matching source",
+			EnvVar: "PLUGIN_RESTORE",
+		},
+		cli.IntFlag{
+			Name:   "restore-days",
+			Usage:  "number of days restored objects stay available",
+			Value:  1,
+			EnvVar: "PLUGIN_RESTORE_DAYS",
+		},
+		cli.StringFlag{
+			Name:   "restore-tier",
+			Usage:  "retrieval tier of the restore (Standard, Bulk or Expedited)",
+			EnvVar: "PLUGIN_RESTORE_TIER",
+		},
+		cli.StringFlag{
+			Name:   "source-bucket",
+			Usage:  "bucket the objects are copied or moved from",
+			EnvVar: "PLUGIN_SOURCE_BUCKET",
+		},
+		cli.IntFlag{
+			Name:   "parallel",
+			Usage:  "number of files to upload concurrently",
+			Value:  1,
+			EnvVar: "PLUGIN_PARALLEL,PLUGIN_CONCURRENCY",
+		},
+		cli.StringFlag{
+			Name:   "schedule",
+			Usage:  "order the files are started in (fifo or largest-first)",
+			Value:  "fifo",
+			EnvVar: "PLUGIN_SCHEDULE",
+		},
+		cli.DurationFlag{
+			Name:   "progress-interval",
+			Usage:  "interval of the upload progress reports",
+			Value:  10 * time.Second,
+			EnvVar: "PLUGIN_PROGRESS_INTERVAL",
+		},
+		cli.StringFlag{
+			Name:   "bandwidth-limit",
+			Usage:  "maximum upload bandwidth shared by all uploads (e.g. 10MB/s)",
+			EnvVar: "PLUGIN_BANDWIDTH_LIMIT",
+		},
+		cli.StringFlag{
+			Name:   "part-size",
+			Usage:  "part size for multipart uploads (e.g. 16MB)",
+			EnvVar: "PLUGIN_PART_SIZE",
+		},
+		cli.IntFlag{
+			Name:   "part-concurrency",
+			Usage:  "number of parts of a file to upload concurrently",
+			EnvVar: "PLUGIN_PART_CONCURRENCY",
+		},
+		cli.StringFlag{
+			Name:   "encryption",
+			Usage:  "server-side encryption algorithm (AES256 or aws:kms)",
+			EnvVar: "PLUGIN_ENCRYPTION",
+		},
+		cli.StringFlag{
+			Name:   "kms-key-id",
+			Usage:  "kms key used for aws:kms server-side encryption",
+			EnvVar: "PLUGIN_KMS_KEY_ID",
+		},
+		cli.StringFlag{
+			Name:   "checksum-algorithm",
+			Usage:  "additional checksum algorithm (CRC32, CRC32C, SHA1 or SHA256)",
+			EnvVar: "PLUGIN_CHECKSUM_ALGORITHM",
+		},
+		cli.BoolFlag{
+			Name:   "content-md5",
+			Usage:  "send the content-md5 header with every upload request",
+			EnvVar: "PLUGIN_CONTENT_MD5",
+		},
+		cli.BoolFlag{
+			Name:   "verify",
+			Usage:  "verify each object after uploading",
+			EnvVar: "PLUGIN_VERIFY",
+		},
+		cli.StringFlag{
+			Name:   "sse-customer-key",
+			Usage:  "customer-provided server-side encryption key",
+			EnvVar: "PLUGIN_SSE_CUSTOMER_KEY",
+		},
+		cli.StringFlag{
+			Name:   "sse-customer-algorithm",
+			Usage:  "customer-provided server-side encryption algorithm",
+			Value:  "AES256",
+			EnvVar: "PLUGIN_SSE_CUSTOMER_ALGORITHM",
+		},
+
+		cli.StringFlag{
+			Name:   "repo",
+			Usage:  "repository full name",
+			EnvVar: "DRONE_REPO",
+		},
+		cli.StringFlag{
+			Name:   "repo.owner",
+			Usage:  "repository owner",
+			EnvVar: "DRONE_REPO_OWNER",
+		},
+		cli.StringFlag{
+			Name:   "repo.name",
+			Usage:  "repository name",
+			EnvVar: "DRONE_REPO_NAME",
+		},
+		cli.StringFlag{
+			Name:   "commit.branch",
+			Usage:  "git commit branch",
+			EnvVar: "DRONE_COMMIT_BRANCH,DRONE_BRANCH",
+		},
+		cli.StringFlag{
+			Name:   "commit.before",
+			Usage:  "git commit sha before the push",
+			EnvVar: "DRONE_COMMIT_BEFORE",
+		},
+		cli.StringFlag{
+			Name:   "commit.after",
+			Usage:  "git commit sha after the push",
+			EnvVar: "DRONE_COMMIT_AFTER",
+		},
+		cli.StringFlag{
+			Name:   "commit.sha",
+			Usage:  "git commit sha",
+			EnvVar: "DRONE_COMMIT_SHA,DRONE_COMMIT",
+		},
+		cli.StringFlag{
+			Name:   "build.tag",
+			Usage:  "build tag",
+			EnvVar: "DRONE_TAG",
+		},
+		cli.StringFlag{
+			Name:   "build.event",
+			Usage:  "build event",
+			EnvVar: "DRONE_BUILD_EVENT",
+		},
+		cli.IntFlag{
+			Name:   "build.number",
+			Usage:  "build number",
+			EnvVar: "DRONE_BUILD_NUMBER",
+		},
+		cli.StringFlag{
+			Name:   "build.deploy",
+			Usage:  "build deployment target",
+			EnvVar: "DRONE_DEPLOY_TO",
+		},
 	}
 
 	if err := app.Run(os.Args); err != nil {
@@ -95,26 +753,317 @@ func main() {
 }
 
 func run(c *cli.Context) error {
-	plugin := Plugin{
-		Endpoint:  c.String("endpoint"),
-		Key:       c.String("access-key"),
-		Secret:    c.String("secret-key"),
-		Bucket:    c.String("bucket"),
-		Region:    c.String("region"),
-		Access:    c.String("acl"),
-		Source:    c.String("source"),
-		Target:    c.String("target"),
-		Recursive: c.Bool("recursive"),
-		Exclude:   c.StringSlice("exclude"),
-		PathStyle: c.Bool("path-style"),
-		DryRun:    c.Bool("dry-run"),
-		Compress:  c.Bool("compress"),
-	}
-
-	// normalize the target URL
-	if strings.HasPrefix(plugin.Target, "/") {
-		plugin.Target = plugin.Target[1:]
-	}
-
-	return plugin.Exec()
+	switch c.String("log-format") {
+	case "text":
+	case "json":
+		log.SetFormatter(&log.JSONFormatter{})
+	default:
+		return fmt.Errorf("unsupported log format %q", c.String("log-format"))
+	}
+
+	switch level := c.String("log-level"); level {
+	case "debug", "info", "warn", "error":
+		lvl, _ := log.ParseLevel(level)
+		log.SetLevel(lvl)
+	default:
+		return fmt.Errorf("unsupported log level %q", level)
+	}
+
+	partSize, err := parseSize(c.String("part-size"))
+	if err != nil {
+		return err
+	}
+
+	bandwidthLimit, err := parseBandwidth(c.String("bandwidth-limit"))
+	if err != nil {
+		return err
+	}
+
+	compressDiskThreshold, err := parseSize(c.String("compress-disk-threshold"))
+	if err != nil {
+		return err
+	}
+
+	minSize, err := parseSize(c.String("min-size"))
+	if err != nil {
+		return err
+	}
+
+	maxSize, err := parseSize(c.String("max-size"))
+	if err != nil {
+		return err
+	}
+
+	maxTotalSize, err := parseSize(c.String("max-total-size"))
+	if err != nil {
+		return err
+	}
+
+	expires, err := parseTime("expires", c.String("expires"), time.Now())
+	if err != nil {
+		return err
+	}
+
+	retainUntil, err := parseTime("object lock retain until", c.String("object-lock-retain-until"), time.Now())
+	if err != nil {
+		return err
+	}
+
+	expireAfter, err := parseDays("expire after", c.String("expire-after"))
+	if err != nil {
+		return err
+	}
+
+	build := Build{
+		Repo:        c.String("repo"),
+		RepoOwner:   c.String("repo.owner"),
+		RepoName:    c.String("repo.name"),
+		Branch:      c.String("commit.branch"),
+		Commit:      c.String("commit.sha"),
+		Tag:         c.String("build.tag"),
+		Event:       c.String("build.event"),
+		BuildNumber: c.Int("build.number"),
+		DeployTo:    c.String("build.deploy"),
+	}
+
+	target, err := render(c.String("target"), build)
+	if err != nil {
+		return err
+	}
+
+	tags, err := render(c.String("tags"), build)
+	if err != nil {
+		return err
+	}
+
+	// the endpoint is followed by the failover endpoints.
+	var endpoint string
+	failoverEndpoints := c.StringSlice("endpoint")
+	if len(failoverEndpoints) != 0 {
+		endpoint, failoverEndpoints = failoverEndpoints[0], failoverEndpoints[1:]
+	}
+
+	plugin := uploader.Options{
+		Endpoint:        endpoint,
+		Key:             c.String("access-key"),
+		Secret:          c.String("secret-key"),
+		Bucket:          c.String("bucket"),
+		Region:          c.String("region"),
+		Access:          c.String("acl"),
+		Source:          c.Generic("source").(*PatternsFlag).Get(),
+		Target:          target,
+		StripPrefix:     c.String("strip-prefix"),
+		Recursive:       c.Bool("recursive"),
+		Exclude:         c.StringSlice("exclude"),
+		PathStyle:       c.Bool("path-style"),
+		DryRun:          c.Bool("dry-run"),
+		Compress:        c.Bool("compress"),
+		Encoding:        c.String("encoding"),
+		ContentType:     c.Generic("content-type").(*StringMapFlag).Get(),
+		OmitCharset:     !c.BoolT("charset"),
+		CacheControl:    c.Generic("cache-control").(*StringMapFlag).Get(),
+		Expires:         expires,
+		StorageClass:    c.Generic("storage-class").(*StringMapFlag).Get(),
+		Metadata:        c.Generic("metadata").(*DeepStringMapFlag).Get(),
+		ContentEncoding: c.Generic("content-encoding").(*StringMapFlag).Get(),
+		Redirects:       c.Generic("redirects").(*StringMapFlag).Get(),
+		Website:         c.Bool("website"),
+		UploadLast:      c.StringSlice("upload-last"),
+		OnlyChanged:     c.Bool("only-changed"),
+		Overwrite:       c.String("overwrite"),
+		Immutable:       c.Bool("immutable"),
+		Atomic:          c.Bool("atomic"),
+		Rollback:        c.Bool("rollback"),
+		ContinueOnError: c.Bool("continue-on-error"),
+		Sync:            c.Bool("sync"),
+		Parallel:        c.Int("parallel"),
+		Schedule:        c.String("schedule"),
+
+		PartSize:        partSize,
+		PartConcurrency: c.Int("part-concurrency"),
+		Encryption:      c.String("encryption"),
+		KMSKeyID:        c.String("kms-key-id"),
+
+		ChecksumAlgorithm: c.String("checksum-algorithm"),
+		ContentMD5:        c.Bool("content-md5"),
+		Verify:            c.Bool("verify"),
+
+		SSECustomerKey:       c.String("sse-customer-key"),
+		SSECustomerAlgorithm: c.String("sse-customer-algorithm"),
+
+		GrantRead:        c.String("grant-read"),
+		GrantReadACP:     c.String("grant-read-acp"),
+		GrantWriteACP:    c.String("grant-write-acp"),
+		GrantFullControl: c.String("grant-full-control"),
+
+		ObjectLockMode:        c.String("object-lock-mode"),
+		ObjectLockRetainUntil: retainUntil,
+		ExpireAfter:           expireAfter,
+		ExpireLifecycleRule:   c.Bool("expire-lifecycle-rule"),
+
+		AssumeRole:      c.String("assume-role"),
+		ExternalID:      c.String("external-id"),
+		RoleSessionName: c.String("role-session-name"),
+
+		RoleARN:              c.String("role-arn"),
+		WebIdentityTokenFile: c.String("web-identity-token-file"),
+
+		Timeout:        c.Duration("timeout"),
+		FileTimeout:    c.Duration("file-timeout"),
+		MaxRetries:     c.Int("max-retries"),
+		RetryBaseDelay: c.Duration("retry-base-delay"),
+		RetryMaxDelay:  c.Duration("retry-max-delay"),
+		RetryMode:      c.String("retry-mode"),
+		RetriesPerFile: c.Int("retries-per-file"),
+
+		CloudFrontDistribution: c.String("cloudfront-distribution"),
+		InvalidationPaths:      c.StringSlice("invalidation-paths"),
+
+		FailOnEmptySource: c.BoolT("fail-on-empty-source"),
+		UseGitignore:      c.Bool("use-gitignore"),
+		ExcludeHidden:     !c.BoolT("include-hidden"),
+		MinSize:           minSize,
+		MaxSize:           maxSize,
+		MaxTotalSize:      maxTotalSize,
+		MaxFiles:          c.Int("max-files"),
+		Symlinks:          c.String("symlinks"),
+		LeadingSlash:      !c.BoolT("strip-leading-slash"),
+		MaxDelete:         c.Int("max-delete"),
+		RetainBuilds:      c.Int("retain-builds"),
+		RetainDays:        c.Int("retain-days"),
+		CreateBucket:      c.Bool("create-bucket"),
+		SkipPreflight:     !c.BoolT("preflight"),
+		PreflightProbe:    c.Bool("preflight-probe"),
+		BucketACL:         c.String("bucket-acl"),
+		UseDualstack:      c.Bool("use-dualstack"),
+		UseFIPS:           c.Bool("use-fips"),
+		RequesterPays:     c.Bool("requester-pays"),
+		Anonymous:         c.Bool("anonymous"),
+		ProgressInterval:  c.Duration("progress-interval"),
+		BandwidthLimit:    bandwidthLimit,
+
+		CompressDiskThreshold: compressDiskThreshold,
+		Endpoints:             c.Generic("endpoints").(*StringMapFlag).Get(),
+		FailoverEndpoints:     failoverEndpoints,
+		SourceBucket:          c.String("source-bucket"),
+		RestoreDays:           c.Int("restore-days"),
+		RestoreTier:           c.String("restore-tier"),
+		RequireEncryption:     c.Bool("require-encryption"),
+		PublicURLs:            c.Bool("public-urls"),
+		GenerateIndex:         c.Bool("generate-index"),
+		FolderMarkers:         c.Bool("create-folder-markers"),
+		PreserveAttributes:    c.Bool("preserve-attributes"),
+		Sitemap:               c.Bool("sitemap"),
+		Fingerprint:           c.StringSlice("fingerprint"),
+		Integrity:             c.String("integrity"),
+		ChangedOnly:           c.Bool("changed-only"),
+		CommitBefore:          c.String("commit.before"),
+		CommitAfter:           c.String("commit.after"),
+		StateFile:             c.String("state-file"),
+		RemoteManifest:        c.Bool("remote-manifest"),
+		BaseURL:               c.String("base-url"),
+		Presign:               c.Bool("presign"),
+		PresignExpires:        c.Duration("presign-expires"),
+		Manifest:              c.String("manifest"),
+
+		CACert:             c.String("ca-cert"),
+		CACertPath:         c.String("ca-cert-path"),
+		InsecureSkipVerify: c.Bool("insecure-skip-verify"),
+		Proxy:              c.String("proxy"),
+
+		ConnectTimeout:        c.Duration("connect-timeout"),
+		ResponseHeaderTimeout: c.Duration("response-header-timeout"),
+		MaxIdleConnsPerHost:   c.Int("max-idle-conns-per-host"),
+	}
+
+	if plugin.Mappings, err = parseMappings(c.String("mappings")); err != nil {
+		return err
+	}
+
+	if plugin.Destinations, err = parseDestinations(c.String("destinations")); err != nil {
+		return err
+	}
+
+	if plugin.WebsiteConfig, err = parseWebsite(c.String("website-config")); err != nil {
+		return err
+	}
+
+	if plugin.CORS, err = parseCORS(c.String("cors")); err != nil {
+		return err
+	}
+
+	if plugin.Rewrites, err = parseRewrites(c.String("rewrites")); err != nil {
+		return err
+	}
+
+	// mapping targets can reference the build like the target.
+	for i := range plugin.Mappings {
+		if plugin.Mappings[i].Target, err = render(plugin.Mappings[i].Target, build); err != nil {
+			return err
+		}
+	}
+
+	// metadata values can reference the build, e.g. {{ .Commit }}
+	for _, metadata := range plugin.Metadata {
+		for k, v := range metadata {
+			if metadata[k], err = render(v, build); err != nil {
+				return err
+			}
+		}
+	}
+
+	if plugin.Tags, err = parseTags(tags); err != nil {
+		return err
+	}
+
+	// never log the credentials, also when they show up in errors.
+	log.AddHook(newRedactHook(logSecrets(plugin)...))
+
+	ctx := context.Background()
+	if c.Bool("download") {
+		return uploader.Download(ctx, plugin)
+	}
+	if c.Bool("copy") {
+		return uploader.Copy(ctx, plugin)
+	}
+	if c.Bool("move") {
+		return uploader.Move(ctx, plugin)
+	}
+	if c.Bool("restore") {
+		return uploader.Restore(ctx, plugin)
+	}
+
+	report, err := uploader.Upload(ctx, plugin)
+
+	// summarize the failed files once all files are uploaded.
+	if plugin.ContinueOnError && err != nil {
+		if werr := report.WriteFailures(os.Stderr); werr != nil {
+			return werr
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	// the renames of the fingerprinted assets are also written by a dry-run,
+	// e.g. to rewrite the references before uploading.
+	if path := c.String("fingerprint-manifest"); path != "" {
+		if err := report.WriteFingerprints(path); err != nil {
+			return err
+		}
+	}
+
+	// print the planned uploads of a dry-run, or write the manifest of the
+	// uploaded files.
+	if c.Bool("dry-run") {
+		if err := report.WriteTable(os.Stdout); err != nil {
+			return err
+		}
+		if path := c.String("dry-run-report"); path != "" {
+			return report.WriteFile(path)
+		}
+	} else if path := c.String("manifest"); path != "" {
+		return report.WriteFile(path)
+	}
+	return nil
 }
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/drone-plugins/drone-s3/uploader"
+)
+
+// parseMappings is a helper function that parses the mappings provided as a
+// JSON list of objects, e.g. [{"source": "dist/**", "target": "/site"}]
+func parseMappings(value string) ([]uploader.Mapping, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, nil
+	}
+
+	var mappings []uploader.Mapping
+	if err := json.Unmarshal([]byte(value), &mappings); err != nil {
+		return nil, fmt.Errorf("invalid mappings: %s", err)
+	}
+	return mappings, nil
+}
@@ -1,240 +0,0 @@
-package main
-
-import (
-	"bytes"
-	"compress/gzip"
-	"io"
-	"mime"
-	"os"
-	"path/filepath"
-	"strings"
-
-	log "github.com/Sirupsen/logrus"
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/credentials"
-	"github.com/aws/aws-sdk-go/aws/session"
-	"github.com/aws/aws-sdk-go/service/s3"
-	"github.com/mattn/go-zglob"
-)
-
-// Plugin defines the S3 plugin parameters.
-type Plugin struct {
-	Endpoint string
-	Key      string
-	Secret   string
-	Bucket   string
-
-	// us-east-1
-	// us-west-1
-	// us-west-2
-	// eu-west-1
-	// ap-southeast-1
-	// ap-southeast-2
-	// ap-northeast-1
-	// sa-east-1
-	Region string
-
-	// Indicates the files ACL, which should be one
-	// of the following:
-	//     private
-	//     public-read
-	//     public-read-write
-	//     authenticated-read
-	//     bucket-owner-read
-	//     bucket-owner-full-control
-	Access string
-
-	// Copies the files from the specified directory.
-	// Regexp matching will apply to match multiple
-	// files
-	//
-	// Examples:
-	//    /path/to/file
-	//    /path/to/*.txt
-	//    /path/to/*/*.txt
-	//    /path/to/**
-	Source string
-	Target string
-
-	// Recursive uploads
-	Recursive bool
-
-	// Exclude files matching this pattern.
-	Exclude []string
-
-	// Use path style instead of domain style.
-	//
-	// Should be true for minio and false for AWS.
-	PathStyle bool
-	// Dry run without uploading/
-	DryRun bool
-	// Compress objects and upload with Content-Encoding: gzip
-	Compress bool
-}
-
-// Exec runs the plugin
-func (p *Plugin) Exec() error {
-	// create the client
-	client := s3.New(session.New(), &aws.Config{
-		Credentials:      credentials.NewStaticCredentials(p.Key, p.Secret, ""),
-		Region:           aws.String(p.Region),
-		Endpoint:         &p.Endpoint,
-		DisableSSL:       aws.Bool(strings.HasPrefix(p.Endpoint, "http://")),
-		S3ForcePathStyle: aws.Bool(p.PathStyle),
-	})
-
-	// find the bucket
-	log.WithFields(log.Fields{
-		"region":   p.Region,
-		"endpoint": p.Endpoint,
-		"bucket":   p.Bucket,
-	}).Info("Attempting to upload")
-
-	matches, err := matches(p.Source, p.Exclude)
-	if err != nil {
-		log.WithFields(log.Fields{
-			"error": err,
-		}).Error("Could not match files")
-		return err
-	}
-
-	for _, match := range matches {
-
-		stat, err := os.Stat(match)
-		if err != nil {
-			continue // should never happen
-		}
-
-		// skip directories
-		if stat.IsDir() {
-			continue
-		}
-
-		target := filepath.Join(p.Target, match)
-		if !strings.HasPrefix(target, "/") {
-			target = "/" + target
-		}
-
-		// amazon S3 has pretty crappy default content-type headers so this pluign
-		// attempts to provide a proper content-type.
-		content := contentType(match)
-
-		// log file for debug purposes.
-		log.WithFields(log.Fields{
-			"name":         match,
-			"bucket":       p.Bucket,
-			"target":       target,
-			"content-type": content,
-		}).Info("Uploading file")
-
-		// when executing a dry-run we exit because we don't actually want to
-		// upload the file to S3.
-		if p.DryRun {
-			continue
-		}
-
-		f, err := os.Open(match)
-		if err != nil {
-			log.WithFields(log.Fields{
-				"error": err,
-				"file":  match,
-			}).Error("Problem opening file")
-			return err
-		}
-		defer f.Close()
-
-		//prepare upload
-		input := &s3.PutObjectInput{
-			Bucket:      &(p.Bucket),
-			Key:         &target,
-			ACL:         &(p.Access),
-			ContentType: &content,
-		}
-
-		//optionally compress
-		if p.Compress {
-			//currently buffers entire file into memory
-			//TODO: convert to on-demand gzip
-			b := bytes.Buffer{}
-			gw := gzip.NewWriter(&b)
-			if _, err := io.Copy(gw, f); err != nil {
-				log.WithFields(log.Fields{
-					"error": err,
-					"file":  match,
-				}).Error("Problem gzipping file")
-				return err
-			}
-			gw.Close()
-			input.Body = bytes.NewReader(b.Bytes())
-			//set encoding
-			input.ContentEncoding = aws.String("gzip")
-		} else {
-			input.Body = f
-		}
-
-		//upload
-		_, err = client.PutObject(input)
-
-		if err != nil {
-			log.WithFields(log.Fields{
-				"name":   match,
-				"bucket": p.Bucket,
-				"target": target,
-				"error":  err,
-			}).Error("Could not upload file")
-
-			return err
-		}
-		f.Close()
-	}
-
-	return nil
-}
-
-// matches is a helper function that returns a list of all files matching the
-// included Glob pattern, while excluding all files that matche the exclusion
-// Glob pattners.
-func matches(include string, exclude []string) ([]string, error) {
-	matches, err := zglob.Glob(include)
-	if err != nil {
-		return nil, err
-	}
-	if len(exclude) == 0 {
-		return matches, nil
-	}
-
-	// find all files that are excluded and load into a map. we can verify
-	// each file in the list is not a member of the exclusion list.
-	excludem := map[string]bool{}
-	for _, pattern := range exclude {
-		excludes, err := zglob.Glob(pattern)
-		if err != nil {
-			return nil, err
-		}
-		for _, match := range excludes {
-			excludem[match] = true
-		}
-	}
-
-	var included []string
-	for _, include := range matches {
-		_, ok := excludem[include]
-		if ok {
-			continue
-		}
-		included = append(included, include)
-	}
-	return included, nil
-}
-
-// contentType is a helper function that returns the content type for the file
-// based on extension. If the file extension is unknown application/octet-stream
-// is returned.
-func contentType(path string) string {
-	ext := filepath.Ext(path)
-	typ := mime.TypeByExtension(ext)
-	if typ == "" {
-		typ = "application/octet-stream"
-	}
-	return typ
-}
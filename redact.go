@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/drone-plugins/drone-s3/uploader"
+)
+
+// redacted replaces the secrets in the log output.
+const redacted = "REDACTED"
+
+// signedPatterns match the signatures, credentials and session tokens of
+// signed requests and presigned URLs, keeping the name in the first group.
+var signedPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)(X-Amz-(?:Signature|Credential|Security-Token)=)[^&\s"']+`),
+	regexp.MustCompile(`(?i)((?:Authorization|X-Amz-Security-Token|X-Amz-Server-Side-Encryption-Customer-Key(?:-MD5)?|X-Amz-Copy-Source-Server-Side-Encryption-Customer-Key)\s*:\s*)[^\r\n]+`),
+}
+
+// redactHook is a log hook replacing the secrets and the signatures of signed
+// requests in the messages and fields of all log entries.
+type redactHook struct {
+	secrets []string
+}
+
+// minSecretLength is the length of the shortest secret that is redacted, so
+// placeholder credentials of test setups don't mangle the whole log.
+const minSecretLength = 6
+
+// logSecrets returns the secrets redacted from the log output, which are the
+// secrets of the options and the session token of the environment.
+func logSecrets(plugin uploader.Options) []string {
+	return append(plugin.Secrets(), os.Getenv("AWS_SESSION_TOKEN"))
+}
+
+// newRedactHook returns a log hook redacting the given secrets. Empty and
+// placeholder secrets are ignored.
+func newRedactHook(secrets ...string) *redactHook {
+	h := &redactHook{}
+	for _, secret := range secrets {
+		if len(secret) >= minSecretLength {
+			h.secrets = append(h.secrets, secret)
+		}
+	}
+	return h
+}
+
+// Levels returns the levels the hook applies to, which are all levels.
+func (h *redactHook) Levels() []log.Level {
+	return []log.Level{
+		log.PanicLevel,
+		log.FatalLevel,
+		log.ErrorLevel,
+		log.WarnLevel,
+		log.InfoLevel,
+		log.DebugLevel,
+	}
+}
+
+// Fire redacts the message and the fields of the entry.
+func (h *redactHook) Fire(entry *log.Entry) error {
+	entry.Message = h.redact(entry.Message)
+	for k, v := range entry.Data {
+		switch value := v.(type) {
+		case string:
+			entry.Data[k] = h.redact(value)
+		case error:
+			entry.Data[k] = h.redact(value.Error())
+		case fmt.Stringer:
+			entry.Data[k] = h.redact(value.String())
+		}
+	}
+	return nil
+}
+
+// redact replaces the secrets and signatures in the text.
+func (h *redactHook) redact(text string) string {
+	for _, secret := range h.secrets {
+		text = strings.Replace(text, secret, redacted, -1)
+	}
+	for _, pattern := range signedPatterns {
+		text = pattern.ReplaceAllString(text, "${1}"+redacted)
+	}
+	return text
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/drone-plugins/drone-s3/uploader"
+)
+
+// testLogger returns a logger writing to the buffer with the hook redacting
+// the secrets of the options.
+func testLogger(plugin uploader.Options) (*log.Logger, *bytes.Buffer) {
+	var buf bytes.Buffer
+	logger := log.New()
+	logger.Out = &buf
+	logger.Formatter = &log.TextFormatter{DisableColors: true}
+	logger.Hooks.Add(newRedactHook(logSecrets(plugin)...))
+	return logger, &buf
+}
+
+func TestRedactSecrets(t *testing.T) {
+	t.Setenv("AWS_SESSION_TOKEN", "session-token-value")
+	plugin := uploader.Options{
+		Key:    "AKIAEXAMPLEKEY",
+		Secret: "secret-access-key",
+		Destinations: []uploader.Destination{
+			{Key: "AKIADESTINATION", Secret: "destination-secret"},
+		},
+	}
+	secrets := []string{"AKIAEXAMPLEKEY", "secret-access-key", "AKIADESTINATION", "destination-secret", "session-token-value"}
+
+	for _, secret := range secrets {
+		logger, buf := testLogger(plugin)
+		logger.WithFields(log.Fields{
+			"field": "value " + secret,
+			"error": errors.New("request with " + secret + " failed"),
+		}).Info("Message with " + secret)
+
+		out := buf.String()
+		if strings.Contains(out, secret) {
+			t.Errorf("secret %s not redacted: %s", secret, out)
+		}
+		if n := strings.Count(out, redacted); n != 3 {
+			t.Errorf("got %d redactions of %s, want 3: %s", n, secret, out)
+		}
+	}
+}
+
+func TestRedactCustomerKey(t *testing.T) {
+	plugin := uploader.Options{SSECustomerKey: "0123456789abcdef0123456789abcdef"}
+	logger, buf := testLogger(plugin)
+
+	for _, secret := range plugin.Secrets() {
+		if secret == "" {
+			continue
+		}
+		logger.WithField("error", errors.New("key "+secret)).Error("Could not upload file")
+	}
+	for _, secret := range plugin.Secrets() {
+		if secret != "" && strings.Contains(buf.String(), secret) {
+			t.Errorf("customer key %s not redacted: %s", secret, buf.String())
+		}
+	}
+}
+
+func TestRedactSigned(t *testing.T) {
+	tests := []struct {
+		name, text, secret string
+	}{
+		{"signature", "https://bucket.s3.amazonaws.com/key?X-Amz-Algorithm=AWS4-HMAC-SHA256&X-Amz-Signature=abcdef0123456789&X-Amz-Date=20200101T000000Z", "abcdef0123456789"},
+		{"credential", "https://bucket.s3.amazonaws.com/key?X-Amz-Credential=AKIAEXAMPLE%2F20200101%2Fus-east-1%2Fs3%2Faws4_request&X-Amz-Expires=900", "AKIAEXAMPLE%2F20200101"},
+		{"security token", "https://bucket.s3.amazonaws.com/key?x-amz-security-token=FwoGZXIvYXdzEBEaDTOKEN&X-Amz-Expires=900", "FwoGZXIvYXdzEBEaDTOKEN"},
+		{"authorization header", "Authorization: AWS4-HMAC-SHA256 Credential=AKIAEXAMPLE/20200101, Signature=abcdef", "AWS4-HMAC-SHA256 Credential"},
+		{"customer key header", "X-Amz-Server-Side-Encryption-Customer-Key: MDEyMzQ1Njc4OWFiY2RlZg==", "MDEyMzQ1Njc4OWFiY2RlZg=="},
+		{"customer key md5 header", "X-Amz-Server-Side-Encryption-Customer-Key-MD5: a2V5bWQ1c3Vt", "a2V5bWQ1c3Vt"},
+		{"copy source customer key header", "X-Amz-Copy-Source-Server-Side-Encryption-Customer-Key: Y29weWtleQ==", "Y29weWtleQ=="},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger, buf := testLogger(uploader.Options{})
+			logger.WithFields(log.Fields{
+				"url":   tt.text,
+				"error": errors.New("request failed: " + tt.text),
+			}).Info(tt.text)
+
+			out := buf.String()
+			if strings.Contains(out, tt.secret) {
+				t.Errorf("%s not redacted: %s", tt.secret, out)
+			}
+			if n := strings.Count(out, redacted); n != 3 {
+				t.Errorf("got %d redactions, want 3: %s", n, out)
+			}
+		})
+	}
+}
+
+func TestRedactPlaceholders(t *testing.T) {
+	logger, buf := testLogger(uploader.Options{Key: "a", Secret: "b"})
+	logger.Info("Uploading a bucket")
+	if strings.Contains(buf.String(), redacted) {
+		t.Errorf("placeholder secrets redacted: %s", buf.String())
+	}
+}
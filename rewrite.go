@@ -0,0 +1,24 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/drone-plugins/drone-s3/uploader"
+)
+
+// parseRewrites is a helper function that parses the rewrite rules provided
+// as a JSON list of objects, e.g. [{"pattern": "^build/", "replacement": ""}]
+func parseRewrites(value string) ([]uploader.Rewrite, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, nil
+	}
+
+	var rewrites []uploader.Rewrite
+	if err := json.Unmarshal([]byte(value), &rewrites); err != nil {
+		return nil, fmt.Errorf("invalid rewrites: %s", err)
+	}
+	return rewrites, nil
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// sizeUnits maps the supported size suffixes to their multiplier.
+var sizeUnits = []struct {
+	suffix string
+	size   int64
+}{
+	{"TB", 1 << 40},
+	{"GB", 1 << 30},
+	{"MB", 1 << 20},
+	{"KB", 1 << 10},
+	{"T", 1 << 40},
+	{"G", 1 << 30},
+	{"M", 1 << 20},
+	{"K", 1 << 10},
+	{"B", 1},
+}
+
+// parseSize is a helper function that parses a human readable size such as
+// 512, 10KB or 1.5GB into a number of bytes. An empty string returns zero.
+func parseSize(value string) (int64, error) {
+	s := strings.ToUpper(strings.TrimSpace(value))
+	if s == "" {
+		return 0, nil
+	}
+
+	mult := int64(1)
+	for _, unit := range sizeUnits {
+		if strings.HasSuffix(s, unit.suffix) {
+			s = strings.TrimSpace(strings.TrimSuffix(s, unit.suffix))
+			mult = unit.size
+			break
+		}
+	}
+
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", value)
+	}
+	return int64(n * float64(mult)), nil
+}
+
+// parseBandwidth is a helper function that parses a human readable bandwidth
+// such as 10MB/s into a number of bytes per second. An empty string returns
+// zero.
+func parseBandwidth(value string) (int64, error) {
+	s := strings.TrimSpace(value)
+	if strings.HasSuffix(strings.ToLower(s), "/s") {
+		s = s[:len(s)-2]
+	}
+	rate, err := parseSize(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid bandwidth %q", value)
+	}
+	return rate, nil
+}
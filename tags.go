@@ -0,0 +1,38 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// parseTags is a helper function that parses object tags provided either as
+// a JSON object or as a list of key=value pairs separated by commas or
+// ampersands, e.g. project=web,env=staging
+func parseTags(value string) (map[string]string, error) {
+	tags := map[string]string{}
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return tags, nil
+	}
+	if strings.HasPrefix(value, "{") {
+		if err := json.Unmarshal([]byte(value), &tags); err != nil {
+			return nil, fmt.Errorf("invalid tags: %s", err)
+		}
+		return tags, nil
+	}
+
+	for _, pair := range strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == '&' }) {
+		parts := strings.SplitN(pair, "=", 2)
+		key := strings.TrimSpace(parts[0])
+		if key == "" {
+			return nil, fmt.Errorf("invalid tag %q", pair)
+		}
+		if len(parts) == 1 {
+			tags[key] = ""
+		} else {
+			tags[key] = strings.TrimSpace(parts[1])
+		}
+	}
+	return tags, nil
+}
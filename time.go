@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// timeFormats lists the supported formats of absolute times.
+var timeFormats = []string{
+	time.RFC3339,
+	http.TimeFormat,
+	"2006-01-02",
+}
+
+// parseDays is a helper function that parses the number of days of the
+// setting, such as 30d or 30. An empty string returns zero.
+func parseDays(setting, value string) (int, error) {
+	s := strings.TrimSpace(value)
+	if s == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(strings.TrimSuffix(s, "d"))
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("invalid %s %q", setting, value)
+	}
+	return n, nil
+}
+
+// parseTime is a helper function that parses the time of the setting, either
+// an absolute time such as 2030-01-01T00:00:00Z or a duration from now such as
+// 24h or 30d. An empty string returns the zero time.
+func parseTime(setting, value string, now time.Time) (time.Time, error) {
+	s := strings.TrimSpace(value)
+	if s == "" {
+		return time.Time{}, nil
+	}
+
+	for _, format := range timeFormats {
+		if t, err := time.Parse(format, s); err == nil {
+			return t, nil
+		}
+	}
+
+	// durations of whole days aren't supported by time.ParseDuration.
+	if days := strings.TrimSuffix(s, "d"); days != s {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return time.Time{}, fmt.Errorf("invalid %s %q", setting, value)
+		}
+		return now.AddDate(0, 0, n), nil
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return time.Time{}, fmt.Errorf("invalid %s %q", setting, value)
+	}
+	return now.Add(d), nil
+}
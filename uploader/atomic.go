@@ -0,0 +1,136 @@
+package uploader
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// stagingPrefix is the prefix of the staging areas of atomic uploads.
+const stagingPrefix = ".drone-s3-staging/"
+
+// staging collects the files uploaded to the staging area of the run, so the
+// files are copied to the target once all files are uploaded.
+type staging struct {
+	prefix string
+
+	mu      sync.Mutex
+	objects []stagedObject
+}
+
+// stagedObject is a file uploaded to the staging area.
+type stagedObject struct {
+	key    string
+	target string
+	rel    string
+	hash   string
+}
+
+// newStaging returns the staging area of a run, unique to the run.
+func newStaging() *staging {
+	return &staging{
+		prefix: stagingPrefix + strconv.FormatInt(time.Now().UnixNano(), 36) + "/",
+	}
+}
+
+// key returns the key of the target in the staging area.
+func (s *staging) key(target string) string {
+	return s.prefix + strings.TrimPrefix(target, "/")
+}
+
+// add records the upload of the file to the staging area.
+func (s *staging) add(key, target, rel, hash string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.objects = append(s.objects, stagedObject{key: key, target: target, rel: rel, hash: hash})
+}
+
+// swap copies the staged files to their targets unless an upload failed,
+// copying the files in the order of the uploads, and removes the staging
+// area. The first error is returned.
+func (o *Options) swap(ctx context.Context, client S3API, err error) error {
+	batches := make([][]stagedObject, 3)
+	for _, obj := range o.staging.objects {
+		i := o.uploadOrder(obj.rel)
+		batches[i] = append(batches[i], obj)
+	}
+
+	if err == nil {
+		log.WithFields(log.Fields{
+			"bucket":  o.Bucket,
+			"staging": o.staging.prefix,
+			"files":   len(o.staging.objects),
+		}).Info("Copying the staged files to the target")
+
+		for _, batch := range batches {
+			if err = o.copyStaged(ctx, client, batch); err != nil {
+				break
+			}
+		}
+	}
+
+	keys := make([]string, len(o.staging.objects))
+	for i, obj := range o.staging.objects {
+		keys[i] = obj.key
+	}
+	log.WithFields(log.Fields{
+		"bucket":  o.Bucket,
+		"staging": o.staging.prefix,
+	}).Info("Removing the staging area")
+	if rerr := o.remove(ctx, client, keys); rerr != nil && err == nil {
+		err = rerr
+	}
+	return err
+}
+
+// copyStaged copies the staged files to their targets in parallel, stopping
+// at the first error.
+func (o *Options) copyStaged(ctx context.Context, client S3API, objects []stagedObject) error {
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		errs   []error
+		queued = make(chan stagedObject)
+	)
+	parallel := o.Parallel
+	if parallel < 1 {
+		parallel = 1
+	}
+	for i := 0; i < parallel; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for obj := range queued {
+				err := o.copyObject(ctx, client, o.Bucket, obj.key, obj.target, obj.rel)
+				mu.Lock()
+				if err != nil {
+					errs = append(errs, err)
+				} else if o.state != nil {
+					o.state.uploaded(o.stateKey(obj.target), obj.hash)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for _, obj := range objects {
+		mu.Lock()
+		failed := len(errs) != 0
+		mu.Unlock()
+		if failed || ctx.Err() != nil {
+			break
+		}
+		queued <- obj
+	}
+	close(queued)
+	wg.Wait()
+
+	if len(errs) != 0 {
+		return errs[0]
+	}
+	return ctx.Err()
+}
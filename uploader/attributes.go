@@ -0,0 +1,75 @@
+package uploader
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// metadata names of the file attributes, following the rsync and s3cmd
+// convention of the mtime in seconds since the epoch and the st_mode in
+// decimal.
+const (
+	mtimeMetadata = "mtime"
+	modeMetadata  = "mode"
+)
+
+// regularFileMode is the st_mode file type bits of a regular file.
+const regularFileMode = 0100000
+
+// fileAttributes returns the metadata holding the modification time and the
+// permissions of the file.
+func fileAttributes(path string) (map[string]string, error) {
+	stat, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{
+		mtimeMetadata: strconv.FormatInt(stat.ModTime().Unix(), 10),
+		modeMetadata:  strconv.FormatUint(uint64(regularFileMode|stat.Mode().Perm()), 10),
+	}, nil
+}
+
+// applyAttributes applies the modification time and the permissions stored
+// in the object metadata to the downloaded file. Attributes missing from the
+// metadata are left as is.
+func applyAttributes(path string, metadata map[string]string) error {
+	var mtime, mode string
+	for k, v := range metadata {
+		switch strings.ToLower(k) {
+		case mtimeMetadata:
+			mtime = v
+		case modeMetadata:
+			mode = v
+		}
+	}
+
+	if mode != "" {
+		// the mode is decimal, though some tools store it in octal.
+		base := 10
+		if strings.HasPrefix(mode, "0") {
+			base = 8
+		}
+		m, err := strconv.ParseUint(mode, base, 32)
+		if err != nil {
+			return err
+		}
+		if err := os.Chmod(path, os.FileMode(m).Perm()); err != nil {
+			return err
+		}
+	}
+
+	if mtime != "" {
+		// tools like rclone store fractions of a second.
+		secs, err := strconv.ParseFloat(mtime, 64)
+		if err != nil {
+			return err
+		}
+		t := time.Unix(0, int64(secs*float64(time.Second)))
+		if err := os.Chtimes(path, t, t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,193 @@
+package uploader
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// createBucket creates the bucket in the configured region when it does not
+// exist yet.
+func (o *Options) createBucket(ctx context.Context, client S3API) error {
+	_, err := client.HeadBucket(ctx, &s3.HeadBucketInput{
+		Bucket: aws.String(o.Bucket),
+	})
+	if err == nil {
+		return nil
+	}
+	if !isNotFound(err) {
+		log.WithFields(log.Fields{
+			"bucket": o.Bucket,
+			"error":  err,
+		}).Error("Could not check the bucket")
+		return err
+	}
+
+	log.WithFields(log.Fields{
+		"bucket": o.Bucket,
+		"region": o.Region,
+		"acl":    o.BucketACL,
+	}).Info("Creating bucket")
+
+	// when executing a dry-run we exit because we don't actually want to
+	// create the bucket.
+	if o.DryRun {
+		return nil
+	}
+
+	input := &s3.CreateBucketInput{
+		Bucket: aws.String(o.Bucket),
+	}
+	// buckets in us-east-1 are created without a location constraint.
+	if o.Region != "" && o.Region != "us-east-1" {
+		input.CreateBucketConfiguration = &types.CreateBucketConfiguration{
+			LocationConstraint: types.BucketLocationConstraint(o.Region),
+		}
+	}
+	if o.BucketACL != "" {
+		input.ACL = types.BucketCannedACL(o.BucketACL)
+	}
+
+	_, err = client.CreateBucket(ctx, input)
+	var owned *types.BucketAlreadyOwnedByYou
+	if errors.As(err, &owned) {
+		err = nil
+	}
+	if err != nil {
+		log.WithFields(log.Fields{
+			"bucket": o.Bucket,
+			"error":  err,
+		}).Error("Could not create the bucket")
+		return err
+	}
+	return nil
+}
+
+// WebsiteConfig is the static website configuration of the bucket, either
+// serving the index and error documents or redirecting all requests to
+// another host.
+type WebsiteConfig struct {
+	IndexDocument string `json:"index_document"`
+	ErrorDocument string `json:"error_document"`
+	// Host the requests are redirected to, optionally with the protocol
+	// like https://example.com.
+	RedirectAllRequestsTo string `json:"redirect_all_requests_to"`
+}
+
+// CORSRule is a cross-origin resource sharing rule of the bucket.
+type CORSRule struct {
+	AllowedOrigins []string `json:"allowed_origins"`
+	AllowedMethods []string `json:"allowed_methods"`
+	AllowedHeaders []string `json:"allowed_headers"`
+	ExposeHeaders  []string `json:"expose_headers"`
+	MaxAgeSeconds  int      `json:"max_age_seconds"`
+}
+
+// corsMethods lists the methods supported by the CORS rules.
+var corsMethods = []string{"GET", "PUT", "POST", "DELETE", "HEAD"}
+
+// configureBucket applies the website and CORS configuration to the bucket,
+// replacing the existing configuration.
+func (o *Options) configureBucket(ctx context.Context, client S3API) error {
+	if o.WebsiteConfig != nil {
+		if err := o.putWebsite(ctx, client); err != nil {
+			return err
+		}
+	}
+	if len(o.CORS) != 0 {
+		if err := o.putCORS(ctx, client); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// putWebsite applies the website configuration to the bucket.
+func (o *Options) putWebsite(ctx context.Context, client S3API) error {
+	config := &types.WebsiteConfiguration{}
+	if w := o.WebsiteConfig; w.RedirectAllRequestsTo != "" {
+		redirect := &types.RedirectAllRequestsTo{HostName: aws.String(w.RedirectAllRequestsTo)}
+		if i := strings.Index(w.RedirectAllRequestsTo, "://"); i != -1 {
+			redirect.Protocol = types.Protocol(w.RedirectAllRequestsTo[:i])
+			redirect.HostName = aws.String(strings.TrimSuffix(w.RedirectAllRequestsTo[i+3:], "/"))
+		}
+		config.RedirectAllRequestsTo = redirect
+	} else {
+		config.IndexDocument = &types.IndexDocument{Suffix: aws.String(w.IndexDocument)}
+		if w.ErrorDocument != "" {
+			config.ErrorDocument = &types.ErrorDocument{Key: aws.String(w.ErrorDocument)}
+		}
+	}
+
+	log.WithFields(log.Fields{
+		"bucket":   o.Bucket,
+		"index":    o.WebsiteConfig.IndexDocument,
+		"error":    o.WebsiteConfig.ErrorDocument,
+		"redirect": o.WebsiteConfig.RedirectAllRequestsTo,
+	}).Info("Configuring the bucket website")
+
+	// when executing a dry-run we exit because we don't actually want to
+	// change the bucket configuration.
+	if o.DryRun {
+		return nil
+	}
+
+	_, err := client.PutBucketWebsite(ctx, &s3.PutBucketWebsiteInput{
+		Bucket:               aws.String(o.Bucket),
+		WebsiteConfiguration: config,
+	})
+	if err != nil {
+		log.WithFields(log.Fields{
+			"bucket": o.Bucket,
+			"error":  err,
+		}).Error("Could not configure the bucket website")
+		return err
+	}
+	return nil
+}
+
+// putCORS applies the CORS rules to the bucket.
+func (o *Options) putCORS(ctx context.Context, client S3API) error {
+	config := &types.CORSConfiguration{}
+	for _, r := range o.CORS {
+		rule := types.CORSRule{
+			AllowedOrigins: r.AllowedOrigins,
+			AllowedMethods: r.AllowedMethods,
+			AllowedHeaders: r.AllowedHeaders,
+			ExposeHeaders:  r.ExposeHeaders,
+		}
+		if r.MaxAgeSeconds > 0 {
+			rule.MaxAgeSeconds = aws.Int32(int32(r.MaxAgeSeconds))
+		}
+		config.CORSRules = append(config.CORSRules, rule)
+	}
+
+	log.WithFields(log.Fields{
+		"bucket": o.Bucket,
+		"rules":  len(config.CORSRules),
+	}).Info("Configuring the bucket CORS rules")
+
+	// when executing a dry-run we exit because we don't actually want to
+	// change the bucket configuration.
+	if o.DryRun {
+		return nil
+	}
+
+	_, err := client.PutBucketCors(ctx, &s3.PutBucketCorsInput{
+		Bucket:            aws.String(o.Bucket),
+		CORSConfiguration: config,
+	})
+	if err != nil {
+		log.WithFields(log.Fields{
+			"bucket": o.Bucket,
+			"error":  err,
+		}).Error("Could not configure the bucket CORS rules")
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package uploader
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// headObject returns the metadata of the remote object, or nil when the
+// object doesn't exist.
+func (o *Options) headObject(ctx context.Context, client S3API, target string) (*s3.HeadObjectOutput, error) {
+	input := &s3.HeadObjectInput{
+		Bucket: aws.String(o.Bucket),
+		Key:    aws.String(target),
+	}
+	if o.SSECustomerKey != "" {
+		input.SSECustomerKey, input.SSECustomerKeyMD5 = sseCustomerKey(o.SSECustomerKey)
+		input.SSECustomerAlgorithm = aws.String(o.SSECustomerAlgorithm)
+	}
+
+	head, err := client.HeadObject(ctx, input)
+	if err != nil {
+		if isNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return head, nil
+}
+
+// sameContent reports whether the remote object has the same content as the
+// local file, comparing the object ETag with the MD5 of the uploaded content.
+// When compress is set the MD5 is computed over the compressed content.
+func (o *Options) sameContent(head *s3.HeadObjectOutput, match, compress string) (bool, error) {
+	r, err := openContent(match, compress)
+	if err != nil {
+		return false, err
+	}
+	defer r.Close()
+
+	single, multi, err := etags(r, o.partSize())
+	if err != nil {
+		return false, err
+	}
+
+	etag := strings.Trim(aws.ToString(head.ETag), `"`)
+	return etag == single || etag == multi, nil
+}
+
+// partSize returns the size of the parts used for multipart uploads.
+func (o *Options) partSize() int64 {
+	if o.PartSize == 0 {
+		return manager.DefaultUploadPartSize
+	}
+	return o.PartSize
+}
+
+// openContent is a helper function that opens the local file for reading the
+// content as uploaded, compressed when compress is set.
+func openContent(match, compress string) (io.ReadCloser, error) {
+	f, err := os.Open(match)
+	if err != nil {
+		return nil, err
+	}
+	if compress == "" {
+		return f, nil
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		defer f.Close()
+		cw := compressor(compress, pw)
+		_, err := io.Copy(cw, f)
+		if err == nil {
+			err = cw.Close()
+		}
+		pw.CloseWithError(err)
+	}()
+	return pr, nil
+}
+
+// etags is a helper function that returns the ETag of the content uploaded
+// in a single part, and the ETag of the content uploaded in multiple parts
+// of the given size.
+func etags(r io.Reader, partSize int64) (string, string, error) {
+	whole := md5.New()
+	var (
+		sums  []byte
+		parts int
+		part  hash.Hash
+	)
+	for {
+		part = md5.New()
+		n, err := io.CopyN(io.MultiWriter(whole, part), r, partSize)
+		if n > 0 {
+			sums = append(sums, part.Sum(nil)...)
+			parts++
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", "", err
+		}
+	}
+
+	multi := md5.Sum(sums)
+	return hex.EncodeToString(whole.Sum(nil)), fmt.Sprintf("%s-%d", hex.EncodeToString(multi[:]), parts), nil
+}
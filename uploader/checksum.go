@@ -0,0 +1,50 @@
+package uploader
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+// newChecksum is a helper function that returns the hash of the additional
+// checksum algorithm.
+func newChecksum(algorithm string) (hash.Hash, error) {
+	switch types.ChecksumAlgorithm(strings.ToUpper(algorithm)) {
+	case types.ChecksumAlgorithmCrc32:
+		return crc32.NewIEEE(), nil
+	case types.ChecksumAlgorithmCrc32c:
+		return crc32.New(crc32.MakeTable(crc32.Castagnoli)), nil
+	case types.ChecksumAlgorithmSha1:
+		return sha1.New(), nil
+	case types.ChecksumAlgorithmSha256:
+		return sha256.New(), nil
+	}
+	return nil, fmt.Errorf("unsupported checksum algorithm %q", algorithm)
+}
+
+// withContentMD5 returns a client option that computes the MD5 of the upload
+// request bodies and sends it in the Content-MD5 header, so S3 rejects content
+// corrupted in transit.
+func withContentMD5() func(*s3.Options) {
+	return func(opts *s3.Options) {
+		opts.APIOptions = append(opts.APIOptions, smithyhttp.AddContentChecksumMiddleware)
+	}
+}
+
+// sseCustomerKey is a helper function that returns the base64 encoded
+// customer-provided key and its MD5, as sent with SSE-C requests.
+func sseCustomerKey(key string) (*string, *string) {
+	raw := customerKey(key)
+	sum := md5.Sum([]byte(raw))
+	return aws.String(base64.StdEncoding.EncodeToString([]byte(raw))), aws.String(base64.StdEncoding.EncodeToString(sum[:]))
+}
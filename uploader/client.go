@@ -0,0 +1,276 @@
+package uploader
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net/http"
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/ratelimit"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/aws/smithy-go/logging"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+// newConfig loads the AWS configuration with the credentials from the
+// options. Unset settings fall back to the environment, the shared config
+// files (including SSO profiles) and the instance metadata (IMDSv2).
+func (o *Options) newConfig(ctx context.Context) (aws.Config, error) {
+	httpClient, err := o.newHTTPClient()
+	if err != nil {
+		return aws.Config{}, err
+	}
+
+	loadOptions := []func(*config.LoadOptions) error{
+		config.WithRegion(o.Region),
+		config.WithHTTPClient(httpClient),
+		// route the SDK log output through the log and its hooks.
+		config.WithLogger(logging.LoggerFunc(func(classification logging.Classification, format string, v ...interface{}) {
+			log.Debugf(format, v...)
+		})),
+		// retry failed requests using exponential backoff with jitter.
+		config.WithRetryer(o.newRetryer),
+		// S3 compatible services often reject the checksums the SDK sends
+		// by default, so only send them when configured.
+		config.WithRequestChecksumCalculation(aws.RequestChecksumCalculationWhenRequired),
+		config.WithResponseChecksumValidation(aws.ResponseChecksumValidationWhenRequired),
+	}
+
+	// log the requests and responses without their bodies when debugging.
+	if log.GetLevel() >= log.DebugLevel {
+		loadOptions = append(loadOptions, config.WithClientLogMode(aws.LogRetries|aws.LogRequest|aws.LogResponse))
+	}
+
+	// resolve the dual-stack (IPv6) and FIPS endpoints of the services.
+	if o.UseDualstack {
+		loadOptions = append(loadOptions, config.WithUseDualStackEndpoint(aws.DualStackEndpointStateEnabled))
+	}
+	if o.UseFIPS {
+		loadOptions = append(loadOptions, config.WithUseFIPSEndpoint(aws.FIPSEndpointStateEnabled))
+	}
+
+	// use the static credentials when provided, otherwise fall back to the
+	// default credential chain (environment, shared config, instance role).
+	// anonymous requests are sent unsigned.
+	if o.Anonymous {
+		loadOptions = append(loadOptions, config.WithCredentialsProvider(aws.AnonymousCredentials{}))
+	} else if o.Key != "" && o.Secret != "" {
+		loadOptions = append(loadOptions, config.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(o.Key, o.Secret, "")))
+	}
+
+	cfg, err := config.LoadDefaultConfig(ctx, loadOptions...)
+	if err != nil {
+		return cfg, err
+	}
+
+	// limit the upload bandwidth across all concurrent requests. the
+	// client of the config is wrapped to keep a custom CA bundle.
+	if o.BandwidthLimit > 0 {
+		cfg.HTTPClient = &throttledClient{
+			base:    cfg.HTTPClient,
+			limiter: newLimiter(o.BandwidthLimit),
+		}
+	}
+
+	// exchange the web identity token (e.g. an EKS service account token)
+	// for role credentials when no static credentials are provided.
+	static := o.Anonymous || o.Key != "" && o.Secret != ""
+	if !static && o.RoleARN != "" && o.WebIdentityTokenFile != "" {
+		provider := stscreds.NewWebIdentityRoleProvider(o.newSTSClient(cfg), o.RoleARN, stscreds.IdentityTokenFile(o.WebIdentityTokenFile), func(opts *stscreds.WebIdentityRoleOptions) {
+			opts.RoleSessionName = o.RoleSessionName
+		})
+		cfg.Credentials = aws.NewCredentialsCache(provider)
+	}
+
+	// assume the role using the base credentials, typically to access a
+	// bucket owned by another account.
+	if o.AssumeRole != "" && !o.Anonymous {
+		provider := stscreds.NewAssumeRoleProvider(o.newSTSClient(cfg), o.AssumeRole, func(opts *stscreds.AssumeRoleOptions) {
+			if o.ExternalID != "" {
+				opts.ExternalID = aws.String(o.ExternalID)
+			}
+			if o.RoleSessionName != "" {
+				opts.RoleSessionName = o.RoleSessionName
+			}
+		})
+		cfg.Credentials = aws.NewCredentialsCache(provider)
+	}
+
+	return cfg, nil
+}
+
+// newRetryer creates the retryer of the retry mode, retrying failed requests
+// using exponential backoff with jitter.
+func (o *Options) newRetryer() aws.Retryer {
+	standard := func(opts *retry.StandardOptions) {
+		opts.MaxAttempts = o.MaxRetries + 1
+		opts.Backoff = &backoff{base: o.RetryBaseDelay, max: o.RetryMaxDelay}
+		// bursts of SlowDown responses would exhaust the retry quota and
+		// fail the uploads, the backoff already spreads the retries.
+		opts.RateLimiter = ratelimit.None
+	}
+
+	var retryer aws.RetryerV2
+	if o.RetryMode == string(aws.RetryModeAdaptive) {
+		retryer = retry.NewAdaptiveMode(func(opts *retry.AdaptiveModeOptions) {
+			opts.StandardOptions = append(opts.StandardOptions, standard)
+		})
+	} else {
+		retryer = retry.NewStandard(standard)
+	}
+
+	// SlowDown responses are retried longer with a backoff of at least a
+	// second, S3 only throttles until it scaled the bucket partitions.
+	slowDown := &backoff{base: o.RetryBaseDelay, max: o.RetryMaxDelay}
+	if slowDown.base < time.Second {
+		slowDown.base = time.Second
+	}
+	if slowDown.max < 20*time.Second {
+		slowDown.max = 20 * time.Second
+	}
+	return &slowDownRetryer{
+		RetryerV2: retryer,
+		attempts:  o.MaxRetries + 1,
+		backoff:   slowDown,
+	}
+}
+
+// backoff is an exponential backoff with full jitter, growing from the base
+// delay up to the maximum delay.
+type backoff struct {
+	base time.Duration
+	max  time.Duration
+}
+
+func (b *backoff) BackoffDelay(attempt int, err error) (time.Duration, error) {
+	delay := b.max
+	if attempt < 32 {
+		if d := b.base << uint(attempt); d > 0 && d < b.max {
+			delay = d
+		}
+	}
+	if delay <= 0 {
+		return 0, nil
+	}
+	return time.Duration(rand.Int63n(int64(delay))), nil
+}
+
+// S3API is the subset of the S3 client operations used by the uploader.
+type S3API interface {
+	manager.UploadAPIClient
+	manager.DownloadAPIClient
+	manager.HeadBucketAPIClient
+	manager.ListObjectsV2APIClient
+	manager.DeleteObjectsAPIClient
+	HeadObject(context.Context, *s3.HeadObjectInput, ...func(*s3.Options)) (*s3.HeadObjectOutput, error)
+	CreateBucket(context.Context, *s3.CreateBucketInput, ...func(*s3.Options)) (*s3.CreateBucketOutput, error)
+	CopyObject(context.Context, *s3.CopyObjectInput, ...func(*s3.Options)) (*s3.CopyObjectOutput, error)
+	UploadPartCopy(context.Context, *s3.UploadPartCopyInput, ...func(*s3.Options)) (*s3.UploadPartCopyOutput, error)
+	GetObjectTagging(context.Context, *s3.GetObjectTaggingInput, ...func(*s3.Options)) (*s3.GetObjectTaggingOutput, error)
+	RestoreObject(context.Context, *s3.RestoreObjectInput, ...func(*s3.Options)) (*s3.RestoreObjectOutput, error)
+	PutBucketWebsite(context.Context, *s3.PutBucketWebsiteInput, ...func(*s3.Options)) (*s3.PutBucketWebsiteOutput, error)
+	PutBucketCors(context.Context, *s3.PutBucketCorsInput, ...func(*s3.Options)) (*s3.PutBucketCorsOutput, error)
+	GetBucketEncryption(context.Context, *s3.GetBucketEncryptionInput, ...func(*s3.Options)) (*s3.GetBucketEncryptionOutput, error)
+	GetBucketLifecycleConfiguration(context.Context, *s3.GetBucketLifecycleConfigurationInput, ...func(*s3.Options)) (*s3.GetBucketLifecycleConfigurationOutput, error)
+	PutBucketLifecycleConfiguration(context.Context, *s3.PutBucketLifecycleConfigurationInput, ...func(*s3.Options)) (*s3.PutBucketLifecycleConfigurationOutput, error)
+}
+
+// newClient creates the S3 client from the options. The custom endpoint only
+// applies to S3, other services use the AWS endpoints.
+func (o *Options) newClient(cfg aws.Config) *s3.Client {
+	return s3.NewFromConfig(cfg, func(opts *s3.Options) {
+		opts.Region = o.Region
+		if o.Endpoint != "" {
+			opts.BaseEndpoint = aws.String(baseEndpoint(o.Endpoint))
+		}
+		if o.Endpoint != "" && len(o.FailoverEndpoints) != 0 {
+			failover := newFailover(append([]string{o.Endpoint}, o.FailoverEndpoints...))
+			opts.EndpointResolverV2 = failover.resolver(s3.NewDefaultEndpointResolverV2())
+			opts.APIOptions = append(opts.APIOptions, failover.apiOption)
+		}
+		opts.UsePathStyle = o.PathStyle
+
+		// the SDK only measures the clock skew when the correction is
+		// enabled.
+		if !cfg.DisableClockSkewCorrection {
+			opts.APIOptions = append(opts.APIOptions, correctClockSkew)
+		}
+
+		// charge the requests to requester pays buckets to the requester.
+		if o.RequesterPays {
+			opts.APIOptions = append(opts.APIOptions, smithyhttp.AddHeaderValue("X-Amz-Request-Payer", "requester"))
+		}
+	})
+}
+
+// newSTSClient creates the STS client used to assume roles, using the custom
+// STS endpoint when configured.
+func (o *Options) newSTSClient(cfg aws.Config) *sts.Client {
+	return sts.NewFromConfig(cfg, func(opts *sts.Options) {
+		if endpoint := o.Endpoints["sts"]; endpoint != "" {
+			opts.BaseEndpoint = aws.String(baseEndpoint(endpoint))
+		}
+	})
+}
+
+// baseEndpoint is a helper function that returns the endpoint URL, using
+// https when the endpoint has no scheme.
+func baseEndpoint(endpoint string) string {
+	if !strings.Contains(endpoint, "://") {
+		return "https://" + endpoint
+	}
+	return endpoint
+}
+
+// detectRegion updates the region to the region of the bucket, which S3
+// reports even for requests sent to the wrong region. It reports whether the
+// region changed.
+func (o *Options) detectRegion(ctx context.Context, client *s3.Client) bool {
+	region, err := manager.GetBucketRegion(ctx, client, o.Bucket)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"bucket": o.Bucket,
+			"error":  err,
+		}).Warn("Could not detect the bucket region")
+		return false
+	}
+	if region == o.Region {
+		return false
+	}
+
+	log.WithFields(log.Fields{
+		"bucket": o.Bucket,
+		"region": region,
+	}).Info("Using the bucket region")
+	o.Region = region
+	return true
+}
+
+// isNotFound is a helper function that reports whether the request failed
+// with a 404 response.
+func isNotFound(err error) bool {
+	var respErr *awshttp.ResponseError
+	return errors.As(err, &respErr) && respErr.HTTPStatusCode() == http.StatusNotFound
+}
+
+// retryFile is a helper function that reports whether the failed upload of a
+// file is attempted again, unless S3 rejected the upload or the context is
+// done.
+func retryFile(ctx context.Context, err error) bool {
+	var respErr *awshttp.ResponseError
+	if errors.As(err, &respErr) && respErr.HTTPStatusCode() < http.StatusInternalServerError {
+		return false
+	}
+	return ctx.Err() == nil
+}
@@ -0,0 +1,65 @@
+package uploader
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudfront"
+	"github.com/aws/aws-sdk-go-v2/service/cloudfront/types"
+)
+
+// invalidate creates a CloudFront invalidation for the uploaded files so the
+// changes are visible immediately. By default all paths under the targets of
+// the mappings are invalidated.
+func (o *Options) invalidate(ctx context.Context, cfg aws.Config, mappings []*Options) error {
+	paths := o.InvalidationPaths
+	if len(paths) == 0 {
+		seen := map[string]bool{}
+		for _, m := range mappings {
+			path := "/" + strings.TrimPrefix(strings.TrimSuffix(m.Target, "/")+"/*", "/")
+			if !seen[path] {
+				seen[path] = true
+				paths = append(paths, path)
+			}
+		}
+	}
+
+	log.WithFields(log.Fields{
+		"distribution": o.CloudFrontDistribution,
+		"paths":        strings.Join(paths, ","),
+	}).Info("Invalidating cache")
+
+	// when executing a dry-run we exit because we don't actually want to
+	// invalidate the distribution.
+	if o.DryRun {
+		return nil
+	}
+
+	client := cloudfront.NewFromConfig(cfg, func(opts *cloudfront.Options) {
+		if endpoint := o.Endpoints["cloudfront"]; endpoint != "" {
+			opts.BaseEndpoint = aws.String(baseEndpoint(endpoint))
+		}
+	})
+	_, err := client.CreateInvalidation(ctx, &cloudfront.CreateInvalidationInput{
+		DistributionId: aws.String(o.CloudFrontDistribution),
+		InvalidationBatch: &types.InvalidationBatch{
+			CallerReference: aws.String(fmt.Sprintf("drone-s3-%d", time.Now().UnixNano())),
+			Paths: &types.Paths{
+				Items:    paths,
+				Quantity: aws.Int32(int32(len(paths))),
+			},
+		},
+	})
+	if err != nil {
+		log.WithFields(log.Fields{
+			"distribution": o.CloudFrontDistribution,
+			"error":        err,
+		}).Error("Could not invalidate cache")
+		return err
+	}
+	return nil
+}
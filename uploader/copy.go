@@ -0,0 +1,254 @@
+package uploader
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// Copy copies the objects matching the source patterns from the source
+// bucket to the target in the bucket with server-side copies, without
+// transferring the content through the runner.
+func Copy(ctx context.Context, opts Options) error {
+	return promote(ctx, opts, false)
+}
+
+// Move moves the objects matching the source patterns from the source bucket
+// to the target in the bucket, copying them server-side and deleting the
+// source objects once all objects are copied.
+func Move(ctx context.Context, opts Options) error {
+	return promote(ctx, opts, true)
+}
+
+// promote copies the matching objects, deleting the source objects when
+// moving them.
+func promote(ctx context.Context, opts Options, move bool) error {
+	if err := opts.validate(); err != nil {
+		return err
+	}
+	opts.Target = strings.TrimPrefix(opts.Target, "/")
+
+	// bound the whole run by the global timeout
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+
+	_, client, err := opts.connect(ctx)
+	if err != nil {
+		return err
+	}
+	copied, err := opts.copy(ctx, client, move)
+	if err != nil || !move {
+		return err
+	}
+
+	// the source objects are kept when any copy failed, so the objects
+	// can be moved again.
+	source := opts
+	source.Bucket = opts.sourceBucket()
+	return source.delete(ctx, client, copied)
+}
+
+// copy copies the matching objects of the source bucket, returning the keys
+// of the copied source objects. When moving, the number of source objects is
+// checked against the maximum number of files to delete before copying, so
+// a move isn't left with all objects duplicated.
+func (o *Options) copy(ctx context.Context, client S3API, move bool) ([]string, error) {
+	source := *o
+	source.Bucket = o.sourceBucket()
+
+	log.WithFields(log.Fields{
+		"region":        o.Region,
+		"endpoint":      o.Endpoint,
+		"bucket":        o.Bucket,
+		"source-bucket": source.Bucket,
+	}).Info("Attempting to copy")
+
+	keys, err := source.matchSources(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+	if move {
+		objects := 0
+		for _, key := range keys {
+			if !strings.HasSuffix(key, "/") {
+				objects++
+			}
+		}
+		if err := source.checkMaxDelete(objects); err != nil {
+			return nil, err
+		}
+	}
+
+	type object struct {
+		key, target string
+	}
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		errs   []error
+		copied []string
+		queued = make(chan object)
+	)
+	parallel := o.Parallel
+	if parallel < 1 {
+		parallel = 1
+	}
+	for i := 0; i < parallel; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for obj := range queued {
+				err := o.copyObject(ctx, client, source.Bucket, obj.key, obj.target, relPath(o.keyPath(obj.key), o.StripPrefix))
+				mu.Lock()
+				if err != nil {
+					errs = append(errs, err)
+				} else {
+					copied = append(copied, obj.key)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for _, key := range keys {
+		mu.Lock()
+		failed := len(errs) != 0
+		mu.Unlock()
+		if failed || ctx.Err() != nil {
+			break
+		}
+
+		// skip folder placeholder objects
+		if strings.HasSuffix(key, "/") {
+			continue
+		}
+
+		target := rewriteKey(resolveKey(o.Target, o.keyPath(key), o.StripPrefix), o.Rewrites)
+		if o.LeadingSlash {
+			target = "/" + target
+		}
+		if source.Bucket == o.Bucket && target == key {
+			mu.Lock()
+			errs = append(errs, fmt.Errorf("can't copy %s onto itself, set a different target", key))
+			mu.Unlock()
+			break
+		}
+		queued <- object{key: key, target: target}
+	}
+	close(queued)
+	wg.Wait()
+
+	if len(errs) != 0 {
+		return copied, errs[0]
+	}
+	if err := ctx.Err(); err != nil {
+		return copied, err
+	}
+	return copied, nil
+}
+
+// copyObject copies a single object, keeping its metadata and tags and
+// applying the access, encryption, storage class and retention settings. The
+// path rel is matched against the storage class patterns.
+func (o *Options) copyObject(ctx context.Context, client S3API, bucket, key, target, rel string) error {
+	// log file for debug purposes.
+	log.WithFields(log.Fields{
+		"name":          key,
+		"source-bucket": bucket,
+		"bucket":        o.Bucket,
+		"target":        target,
+	}).Info("Copying object")
+
+	// when executing a dry-run we exit because we don't actually want to
+	// copy the object.
+	if o.DryRun {
+		return nil
+	}
+
+	// the ACL settings are shared with the uploads.
+	acl := &s3.PutObjectInput{}
+	o.applyACL(acl)
+
+	input := &s3.CopyObjectInput{
+		Bucket:           aws.String(o.Bucket),
+		Key:              aws.String(target),
+		CopySource:       aws.String(copySource(bucket, key)),
+		ACL:              acl.ACL,
+		GrantRead:        acl.GrantRead,
+		GrantReadACP:     acl.GrantReadACP,
+		GrantWriteACP:    acl.GrantWriteACP,
+		GrantFullControl: acl.GrantFullControl,
+	}
+	if o.Encryption != "" {
+		input.ServerSideEncryption = types.ServerSideEncryption(o.Encryption)
+	}
+	if o.KMSKeyID != "" {
+		input.SSEKMSKeyId = aws.String(o.KMSKeyID)
+	}
+	if o.SSECustomerKey != "" {
+		input.SSECustomerKey, input.SSECustomerKeyMD5 = sseCustomerKey(o.SSECustomerKey)
+		input.SSECustomerAlgorithm = aws.String(o.SSECustomerAlgorithm)
+		input.CopySourceSSECustomerKey, input.CopySourceSSECustomerKeyMD5 = input.SSECustomerKey, input.SSECustomerKeyMD5
+		input.CopySourceSSECustomerAlgorithm = input.SSECustomerAlgorithm
+	}
+	if storageClass := lookup(o.StorageClass, rel); storageClass != "" {
+		input.StorageClass = types.StorageClass(storageClass)
+	}
+	if o.objectLock() {
+		input.ObjectLockMode = types.ObjectLockMode(o.ObjectLockMode)
+		input.ObjectLockRetainUntilDate = aws.Time(o.ObjectLockRetainUntil)
+	}
+
+	// single copies are limited to 5 GiB, larger objects are copied in
+	// parts.
+	head, err := client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket:               aws.String(bucket),
+		Key:                  aws.String(key),
+		SSECustomerAlgorithm: input.CopySourceSSECustomerAlgorithm,
+		SSECustomerKey:       input.CopySourceSSECustomerKey,
+		SSECustomerKeyMD5:    input.CopySourceSSECustomerKeyMD5,
+	})
+	if err == nil {
+		if aws.ToInt64(head.ContentLength) > maxCopySize {
+			err = o.copyParts(ctx, client, input, bucket, key, head)
+		} else {
+			_, err = client.CopyObject(ctx, input)
+		}
+	}
+	if err != nil {
+		log.WithFields(log.Fields{
+			"name":          key,
+			"source-bucket": bucket,
+			"bucket":        o.Bucket,
+			"target":        target,
+			"error":         err,
+		}).Error("Could not copy object")
+		return err
+	}
+	return nil
+}
+
+// sourceBucket returns the bucket the objects are copied from, defaulting to
+// the bucket.
+func (o *Options) sourceBucket() string {
+	if o.SourceBucket != "" {
+		return o.SourceBucket
+	}
+	return o.Bucket
+}
+
+// copySource is a helper function that returns the URL encoded copy source of
+// the object.
+func copySource(bucket, key string) string {
+	return (&url.URL{Path: bucket + "/" + key}).EscapedPath()
+}
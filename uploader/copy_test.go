@@ -0,0 +1,108 @@
+package uploader
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+func TestCopyParts(t *testing.T) {
+	defer func(size, part int64) { maxCopySize, copyPartSize = size, part }(maxCopySize, copyPartSize)
+	maxCopySize, copyPartSize = 10, 4
+
+	body := []byte("0123456789abcdefghij-")
+	m := NewMemory("source", "bucket")
+	for key, content := range map[string][]byte{"large.bin": body, "small.bin": body[:5]} {
+		_, err := m.PutObject(context.Background(), &s3.PutObjectInput{
+			Bucket:       aws.String("source"),
+			Key:          aws.String(key),
+			Body:         bytes.NewReader(content),
+			ContentType:  aws.String("application/octet-stream"),
+			CacheControl: aws.String("max-age=60"),
+			Metadata:     map[string]string{"build": "42"},
+			Tagging:      aws.String("env=staging"),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err := Copy(context.Background(), Options{
+		Client:        m,
+		SkipPreflight: true,
+		Bucket:        "bucket",
+		SourceBucket:  "source",
+		Source:        []string{"*.bin"},
+		Target:        "copied",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for key, want := range map[string][]byte{"copied/large.bin": body, "copied/small.bin": body[:5]} {
+		object := m.Object("bucket", key)
+		if object == nil {
+			t.Fatalf("object %s not copied", key)
+		}
+		if !bytes.Equal(object.Body, want) {
+			t.Errorf("got %s content %q, want %q", key, object.Body, want)
+		}
+		if got := aws.ToString(object.Input.ContentType); got != "application/octet-stream" {
+			t.Errorf("got %s content type %q", key, got)
+		}
+		if got := aws.ToString(object.Input.CacheControl); got != "max-age=60" {
+			t.Errorf("got %s cache control %q", key, got)
+		}
+		if !reflect.DeepEqual(object.Input.Metadata, map[string]string{"build": "42"}) {
+			t.Errorf("got %s metadata %v", key, object.Input.Metadata)
+		}
+		if got := aws.ToString(object.Input.Tagging); got != "env=staging" {
+			t.Errorf("got %s tags %q", key, got)
+		}
+	}
+
+	// the ETag of the objects copied in parts has the number of parts.
+	if etag := m.Object("bucket", "copied/large.bin").ETag; !strings.HasSuffix(strings.Trim(etag, `"`), "-6") {
+		t.Errorf("got ETag %s of large.bin, want 6 parts", etag)
+	}
+	if etag := m.Object("bucket", "copied/small.bin").ETag; strings.Contains(etag, "-") {
+		t.Errorf("got ETag %s of small.bin copied in parts", etag)
+	}
+}
+
+func TestMoveMaxDelete(t *testing.T) {
+	m := NewMemory("source", "bucket")
+	for _, key := range []string{"a.bin", "b.bin", "c.bin"} {
+		_, err := m.PutObject(context.Background(), &s3.PutObjectInput{
+			Bucket: aws.String("source"),
+			Key:    aws.String(key),
+			Body:   strings.NewReader(key),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err := Move(context.Background(), Options{
+		Client:        m,
+		SkipPreflight: true,
+		Bucket:        "bucket",
+		SourceBucket:  "source",
+		Source:        []string{"*.bin"},
+		MaxDelete:     2,
+	})
+	if err == nil {
+		t.Fatal("got no error moving more objects than the maximum to delete")
+	}
+	if keys := m.Keys("bucket"); len(keys) != 0 {
+		t.Errorf("got copied objects %v, want none", keys)
+	}
+	if keys := m.Keys("source"); len(keys) != 3 {
+		t.Errorf("got source objects %v, want all 3", keys)
+	}
+}
@@ -0,0 +1,82 @@
+package uploader
+
+import (
+	"context"
+	"fmt"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// Destination is an additional bucket the files are uploaded to, e.g. a
+// mirror in another region or at another provider. Settings a destination
+// does not set default to the step settings.
+type Destination struct {
+	Bucket    string `json:"bucket"`
+	Region    string `json:"region"`
+	Endpoint  string `json:"endpoint"`
+	Key       string `json:"access_key"`
+	Secret    string `json:"secret_key"`
+	PathStyle *bool  `json:"path_style"`
+}
+
+// destinations returns a copy of the options for each destination, with the
+// destination settings applied.
+func (o *Options) destinations() []*Options {
+	destinations := make([]*Options, len(o.Destinations))
+	for i, d := range o.Destinations {
+		opts := *o
+		opts.Destinations = nil
+		opts.Bucket = d.Bucket
+		if d.Region != "" {
+			opts.Region = d.Region
+		}
+		if d.Endpoint != "" {
+			opts.Endpoint = d.Endpoint
+			opts.FailoverEndpoints = nil
+		}
+		if d.Key != "" {
+			opts.Key = d.Key
+			opts.Secret = d.Secret
+		}
+		if d.PathStyle != nil {
+			opts.PathStyle = *d.PathStyle
+		}
+		// the CloudFront distribution serves the bucket of the step.
+		opts.CloudFrontDistribution = ""
+		destinations[i] = &opts
+	}
+	return destinations
+}
+
+// fanOut uploads the files to the destinations after the bucket, adding the
+// reports of the destinations to the report. All destinations are uploaded
+// to, the first error is returned once done.
+func (o *Options) fanOut(ctx context.Context, report *Report, err error) error {
+	errs := []error{err}
+	for _, d := range o.destinations() {
+		r, derr := d.exec(ctx)
+		r.Bucket = d.Bucket
+		report.Destinations = append(report.Destinations, r)
+		errs = append(errs, derr)
+		if derr != nil && err == nil {
+			err = fmt.Errorf("destination %s: %s", d.Bucket, derr)
+		}
+	}
+
+	// log the status of each bucket once all uploads finished.
+	for i, r := range append([]Report{*report}, report.Destinations...) {
+		fields := log.Fields{
+			"bucket":   r.Bucket,
+			"uploaded": r.Uploaded,
+			"skipped":  r.Skipped,
+			"failed":   r.Failed,
+		}
+		if errs[i] != nil {
+			fields["error"] = errs[i]
+			log.WithFields(fields).Error("Could not upload to the destination")
+		} else {
+			log.WithFields(fields).Info("Uploaded to the destination")
+		}
+	}
+	return err
+}
@@ -0,0 +1,242 @@
+package uploader
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/mattn/go-zglob"
+)
+
+// download fetches all objects with keys matching the source pattern into
+// the target folder.
+func (o *Options) download(ctx context.Context, client S3API) error {
+	log.WithFields(log.Fields{
+		"region":   o.Region,
+		"endpoint": o.Endpoint,
+		"bucket":   o.Bucket,
+	}).Info("Attempting to download")
+
+	keys, err := o.matchSources(ctx, client)
+	if err != nil {
+		return err
+	}
+
+	downloader := manager.NewDownloader(client, func(d *manager.Downloader) {
+		if o.PartSize != 0 {
+			d.PartSize = o.PartSize
+		}
+		if o.PartConcurrency != 0 {
+			d.Concurrency = o.PartConcurrency
+		}
+	})
+
+	for _, key := range keys {
+		// skip folder placeholder objects
+		if strings.HasSuffix(key, "/") {
+			continue
+		}
+
+		// keys with .. segments could write files outside of the target
+		// folder, so these are skipped.
+		rel, ok := downloadPath(strings.TrimPrefix(o.keyPath(key), o.StripPrefix))
+		if !ok {
+			log.WithFields(log.Fields{
+				"name":   key,
+				"bucket": o.Bucket,
+			}).Warn("Skipping object outside of the target folder")
+			continue
+		}
+		target := filepath.Join(o.Target, rel)
+
+		// log file for debug purposes.
+		log.WithFields(log.Fields{
+			"name":   key,
+			"bucket": o.Bucket,
+			"target": target,
+		}).Info("Downloading file")
+
+		// when executing a dry-run we exit because we don't actually want to
+		// download the file from S3.
+		if o.DryRun {
+			continue
+		}
+
+		if err := o.downloadFile(ctx, downloader, key, target); err != nil {
+			log.WithFields(log.Fields{
+				"name":   key,
+				"bucket": o.Bucket,
+				"target": target,
+				"error":  err,
+			}).Error("Could not download file")
+			return err
+		}
+
+		if o.PreserveAttributes {
+			if err := o.restoreAttributes(ctx, client, key, target); err != nil {
+				log.WithFields(log.Fields{
+					"name":   key,
+					"bucket": o.Bucket,
+					"target": target,
+					"error":  err,
+				}).Error("Could not restore the file attributes")
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// restoreAttributes applies the modification time and the permissions stored
+// in the metadata of the object to the downloaded file.
+func (o *Options) restoreAttributes(ctx context.Context, client S3API, key, target string) error {
+	head, err := o.headObject(ctx, client, key)
+	if err != nil || head == nil {
+		return err
+	}
+	return applyAttributes(target, head.Metadata)
+}
+
+// downloadPath is a helper function that returns the cleaned local path of
+// the key relative to the target folder, reporting whether the path stays
+// inside the target folder.
+func downloadPath(key string) (string, bool) {
+	rel := filepath.Clean(filepath.FromSlash(strings.TrimLeft(key, "/")))
+	if filepath.IsAbs(rel) || filepath.VolumeName(rel) != "" || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return rel, true
+}
+
+// downloadFile downloads a single object to the target file, creating the
+// parent folders as needed.
+func (o *Options) downloadFile(ctx context.Context, downloader *manager.Downloader, key, target string) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+
+	f, err := os.Create(target)
+	if err != nil {
+		return err
+	}
+
+	input := &s3.GetObjectInput{
+		Bucket: aws.String(o.Bucket),
+		Key:    aws.String(key),
+	}
+	if o.SSECustomerKey != "" {
+		input.SSECustomerKey, input.SSECustomerKeyMD5 = sseCustomerKey(o.SSECustomerKey)
+		input.SSECustomerAlgorithm = aws.String(o.SSECustomerAlgorithm)
+	}
+
+	_, err = downloader.Download(ctx, f, input)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// matchSources returns the keys in the bucket matching any of the source
+// patterns, once each.
+func (o *Options) matchSources(ctx context.Context, client S3API) ([]string, error) {
+	var keys []string
+	seen := map[string]bool{}
+	for _, pattern := range o.Source {
+		matched, err := o.matchKeys(ctx, client, strings.TrimPrefix(pattern, "/"), o.Exclude)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"bucket": o.Bucket,
+				"error":  err,
+			}).Error("Could not match files")
+			return nil, err
+		}
+		for _, key := range matched {
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+	return keys, nil
+}
+
+// matchKeys is a helper function that returns a list of all keys in the bucket
+// matching the included Glob pattern, while excluding all keys that match the
+// exclusion Glob patterns.
+func (o *Options) matchKeys(ctx context.Context, client S3API, include string, exclude []string) ([]string, error) {
+	// only list the objects under the literal prefix of the pattern.
+	prefix := include
+	if i := strings.IndexAny(prefix, "*?[{"); i != -1 {
+		prefix = prefix[:i]
+	}
+	if o.LeadingSlash {
+		prefix = "/" + prefix
+	}
+
+	var keys []string
+	paginator := s3.NewListObjectsV2Paginator(client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(o.Bucket),
+		Prefix: aws.String(prefix),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, object := range page.Contents {
+			keys = append(keys, aws.ToString(object.Key))
+		}
+	}
+
+	var included []string
+	for _, key := range keys {
+		ok, err := matchKey(include, o.keyPath(key))
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		excluded := false
+		for _, pattern := range exclude {
+			if excluded, err = matchKey(strings.TrimPrefix(pattern, "/"), o.keyPath(key)); err != nil {
+				return nil, err
+			}
+			if excluded {
+				break
+			}
+		}
+		if !excluded {
+			included = append(included, key)
+		}
+	}
+	return included, nil
+}
+
+// keyPath returns the key the patterns are matched against, which is the key
+// without the leading slash of keys uploaded with a leading slash.
+func (o *Options) keyPath(key string) string {
+	if o.LeadingSlash {
+		return strings.TrimPrefix(key, "/")
+	}
+	return key
+}
+
+// matchKey is a helper function that reports whether the key matches the Glob
+// pattern. A pattern without Glob characters matches the key itself and all
+// keys below it.
+func matchKey(pattern, key string) (bool, error) {
+	if pattern == "" {
+		return true, nil
+	}
+	if !strings.ContainsAny(pattern, "*?[{") {
+		return key == pattern || strings.HasPrefix(key, strings.TrimSuffix(pattern, "/")+"/"), nil
+	}
+	return zglob.Match(pattern, key)
+}
@@ -0,0 +1,46 @@
+package uploader
+
+import (
+	"context"
+	"fmt"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// requireEncryption checks the bucket encrypts the objects by default, so the
+// files are never stored in plaintext when the encryption settings of the
+// step are missing.
+func (o *Options) requireEncryption(ctx context.Context, client S3API) error {
+	out, err := client.GetBucketEncryption(ctx, &s3.GetBucketEncryptionInput{
+		Bucket: aws.String(o.Bucket),
+	})
+	// buckets without default encryption return a 404 response.
+	if err != nil && !isNotFound(err) {
+		log.WithFields(log.Fields{
+			"bucket": o.Bucket,
+			"error":  err,
+		}).Error("Could not get the bucket encryption")
+		return err
+	}
+
+	if err == nil && out.ServerSideEncryptionConfiguration != nil {
+		for _, rule := range out.ServerSideEncryptionConfiguration.Rules {
+			if rule.ApplyServerSideEncryptionByDefault == nil {
+				continue
+			}
+
+			log.WithFields(log.Fields{
+				"bucket":     o.Bucket,
+				"encryption": rule.ApplyServerSideEncryptionByDefault.SSEAlgorithm,
+			}).Info("Bucket is encrypted by default")
+			return nil
+		}
+	}
+
+	log.WithFields(log.Fields{
+		"bucket": o.Bucket,
+	}).Error("Bucket is not encrypted by default")
+	return fmt.Errorf("bucket %s has no default encryption, enable the default encryption of the bucket", o.Bucket)
+}
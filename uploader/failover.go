@@ -0,0 +1,120 @@
+package uploader
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	smithyendpoints "github.com/aws/smithy-go/endpoints"
+	"github.com/aws/smithy-go/middleware"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+// failover sends the requests to the active endpoint of a list of endpoints,
+// failing over to the next endpoint when an endpoint can't be reached. The
+// endpoint that took over stays active for the following requests.
+type failover struct {
+	endpoints []string
+
+	mu     sync.Mutex
+	active int
+}
+
+// newFailover returns a failover starting with the first endpoint.
+func newFailover(endpoints []string) *failover {
+	f := &failover{}
+	for _, endpoint := range endpoints {
+		f.endpoints = append(f.endpoints, baseEndpoint(endpoint))
+	}
+	return f
+}
+
+// current returns the index of the active endpoint.
+func (f *failover) current() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.active
+}
+
+// failed activates the endpoint following the failed endpoint, unless a
+// concurrent request failed over already.
+func (f *failover) failed(i int, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.active != i {
+		return
+	}
+	f.active = (i + 1) % len(f.endpoints)
+
+	log.WithFields(log.Fields{
+		"endpoint": f.endpoints[i],
+		"next":     f.endpoints[f.active],
+		"error":    err,
+	}).Warn("Failing over to the next endpoint")
+}
+
+// failoverEndpointKey is the stack value key of the index of the endpoint a
+// request attempt is sent to.
+type failoverEndpointKey struct{}
+
+// resolver wraps the endpoint resolver of the client, resolving the endpoint
+// of the request attempt instead of the base endpoint.
+func (f *failover) resolver(resolver s3.EndpointResolverV2) s3.EndpointResolverV2 {
+	return failoverResolver{EndpointResolverV2: resolver, failover: f}
+}
+
+type failoverResolver struct {
+	s3.EndpointResolverV2
+	failover *failover
+}
+
+func (r failoverResolver) ResolveEndpoint(ctx context.Context, params s3.EndpointParameters) (smithyendpoints.Endpoint, error) {
+	if i, ok := middleware.GetStackValue(ctx, failoverEndpointKey{}).(int); ok {
+		params.Endpoint = aws.String(r.failover.endpoints[i])
+	}
+	return r.EndpointResolverV2.ResolveEndpoint(ctx, params)
+}
+
+// apiOption adds the middleware sending each request attempt to the active
+// endpoint, and trying the following endpoints in turn when the request
+// can't be sent. The retries of the attempt start over with the endpoint
+// that took over.
+func (f *failover) apiOption(stack *middleware.Stack) error {
+	attempt := middleware.FinalizeMiddlewareFunc("EndpointFailover", func(ctx context.Context, in middleware.FinalizeInput, next middleware.FinalizeHandler) (middleware.FinalizeOutput, middleware.Metadata, error) {
+		req, ok := in.Request.(*smithyhttp.Request)
+		if !ok {
+			return next.HandleFinalize(ctx, in)
+		}
+
+		for tried := 1; ; tried++ {
+			// the endpoint is resolved into the request, so every endpoint
+			// is sent a clone of the request.
+			attempt := in
+			attempt.Request = req.Clone()
+			if tried > 1 {
+				if err := req.RewindStream(); err != nil {
+					return middleware.FinalizeOutput{}, middleware.Metadata{}, err
+				}
+			}
+
+			i := f.current()
+			out, metadata, err := next.HandleFinalize(middleware.WithStackValue(ctx, failoverEndpointKey{}, i), attempt)
+			if err == nil || tried == len(f.endpoints) || !isConnectionError(ctx, err) {
+				return out, metadata, err
+			}
+			f.failed(i, err)
+		}
+	})
+	return stack.Finalize.Insert(attempt, "Retry", middleware.After)
+}
+
+// isConnectionError is a helper function that reports whether the request
+// failed without a response, e.g. because the endpoint is down, unless the
+// context is done.
+func isConnectionError(ctx context.Context, err error) bool {
+	var sendErr *smithyhttp.RequestSendError
+	return errors.As(err, &sendErr) && ctx.Err() == nil
+}
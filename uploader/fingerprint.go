@@ -0,0 +1,91 @@
+package uploader
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// fingerprintLength is the number of hex digits of the content hash added to
+// the names of the fingerprinted files.
+const fingerprintLength = 8
+
+// immutableCacheControl is the Cache-Control header of files with a content
+// hash in the name, which never change.
+const immutableCacheControl = "public, max-age=31536000, immutable"
+
+// fingerprinted reports whether the file is renamed with its content hash.
+func (o *Options) fingerprinted(rel string) bool {
+	for _, pattern := range o.Fingerprint {
+		if matchPattern(pattern, rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// fingerprint is a helper function that returns the content hash of the
+// file added to its name.
+func fingerprint(name string) (string, error) {
+	hash, err := fileHash(name)
+	if err != nil {
+		return "", err
+	}
+	return hash[:fingerprintLength], nil
+}
+
+// fileHash is a helper function that returns the hex encoded SHA-256 hash of
+// the content of the file.
+func fileHash(name string) (string, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// fingerprintKey is a helper function that adds the hash to the name of the
+// key before the extension, e.g. assets/app.js to assets/app.3f9ab2c1.js.
+// The extensions of compressed files are kept together, like app.js.gz.
+func fingerprintKey(key, hash string) string {
+	name := trimCompressedExt(key)
+	ext := path.Ext(name)
+	if strings.Contains(ext, "/") {
+		ext = ""
+	}
+	return strings.TrimSuffix(name, ext) + "." + hash + ext + key[len(name):]
+}
+
+// WriteFingerprints writes the keys of the fingerprinted files relative to the
+// target to the file as a JSON object, keyed by the keys without hash.
+func (r Report) WriteFingerprints(path string) error {
+	fingerprints := r.Fingerprints
+	if fingerprints == nil {
+		fingerprints = map[string]string{}
+	}
+	out, err := json.MarshalIndent(fingerprints, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(path, append(out, '\n'), 0644); err != nil {
+		log.WithFields(log.Fields{
+			"file":  path,
+			"error": err,
+		}).Error("Could not write the fingerprints")
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+package uploader
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+)
+
+// folderContentType is the content type of the folder marker objects.
+const folderContentType = "application/x-directory"
+
+// folderMarkers uploads an empty folder marker object like docs/ for each
+// directory of the uploaded keys, as expected by S3 browsers and legacy
+// tooling. The bucket root has no marker.
+func (o *Options) folderMarkers(ctx context.Context, uploader *manager.Uploader, uploads *manifest, uploaded map[string]bool) error {
+	for _, dir := range o.indexDirs(uploaded) {
+		if dir == "" || dir == "/" || uploaded[dir] {
+			continue
+		}
+		uploaded[dir] = true
+
+		err := o.putGenerated(ctx, uploader, uploads, "folder marker", dir, folderContentType, func() ([]byte, error) {
+			return nil, nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
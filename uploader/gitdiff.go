@@ -0,0 +1,75 @@
+package uploader
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// changedFiles returns the files changed by the commit range, relative to
+// the working directory, or nil to upload all files when the range can't be
+// diffed, e.g. for the first push of a branch or a shallow clone without the
+// commit before.
+func (o *Options) changedFiles(ctx context.Context) map[string]bool {
+	before, after := o.CommitBefore, o.CommitAfter
+	if after == "" {
+		after = "HEAD"
+	}
+	fields := log.Fields{
+		"before": before,
+		"after":  after,
+	}
+
+	// the commit before of new branches is empty or all zeros.
+	if strings.Trim(before, "0") == "" {
+		log.WithFields(fields).Warn("No commit before to diff, uploading all files")
+		return nil
+	}
+
+	cmd := exec.CommandContext(ctx, "git", "diff", "--name-only", "--relative", "-z", before+".."+after)
+	out, err := cmd.Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			fields["output"] = strings.TrimSpace(string(exitErr.Stderr))
+		}
+		fields["error"] = err
+		log.WithFields(fields).Warn("Could not diff the commits, uploading all files")
+		return nil
+	}
+
+	changed := map[string]bool{}
+	for _, name := range bytes.Split(out, []byte{0}) {
+		if len(name) != 0 {
+			changed[filepath.Clean(filepath.FromSlash(string(name)))] = true
+		}
+	}
+	fields["changed"] = len(changed)
+	log.WithFields(fields).Info("Uploading the files changed by the commits")
+	return changed
+}
+
+// changedFile reports whether the file is changed by the commit range, with
+// absolute paths resolved against the working directory.
+func (o *Options) changedFile(name string) bool {
+	if o.changed == nil {
+		return true
+	}
+	name = filepath.Clean(name)
+	if filepath.IsAbs(name) {
+		wd, err := os.Getwd()
+		if err != nil {
+			return true
+		}
+		if rel, err := filepath.Rel(wd, name); err == nil {
+			name = rel
+		}
+	}
+	return o.changed[name]
+}
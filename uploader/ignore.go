@@ -0,0 +1,159 @@
+package uploader
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	ignore "github.com/sabhiram/go-gitignore"
+)
+
+// ignoreFile is the name of the file in the source root listing the files that
+// are not uploaded, using the gitignore syntax.
+const ignoreFile = ".s3ignore"
+
+// ignored is a helper function that removes the files ignored by the ignore
+// files in the roots of the source patterns. The ignore files themselves are
+// removed as well.
+func ignored(files, include []string) ([]string, error) {
+	type rule struct {
+		root   string
+		ignore *ignore.GitIgnore
+	}
+
+	var rules []rule
+	seen := map[string]bool{}
+	for _, pattern := range include {
+		root := sourceRoot(pattern)
+		if seen[root] {
+			continue
+		}
+		seen[root] = true
+
+		path := filepath.Join(root, ignoreFile)
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			continue
+		}
+		gi, err := ignore.CompileIgnoreFile(path)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule{root: root, ignore: gi})
+	}
+
+	var kept []string
+	for _, file := range files {
+		if filepath.Base(file) == ignoreFile {
+			continue
+		}
+		skip := false
+		for _, r := range rules {
+			rel, err := filepath.Rel(r.root, file)
+			if err != nil || strings.HasPrefix(rel, "..") {
+				continue
+			}
+			if r.ignore.MatchesPath(filepath.ToSlash(rel)) {
+				skip = true
+				break
+			}
+		}
+		if !skip {
+			kept = append(kept, file)
+		}
+	}
+	return kept, nil
+}
+
+// sourceRoot is a helper function that returns the folder of the literal
+// prefix of the Glob pattern, e.g. dist for dist/**/*.js
+func sourceRoot(pattern string) string {
+	if i := strings.IndexAny(pattern, "*?[{"); i != -1 {
+		pattern = pattern[:i]
+	}
+	if pattern == "" {
+		return "."
+	}
+	if strings.HasSuffix(pattern, "/") {
+		return filepath.Clean(pattern)
+	}
+	return filepath.Dir(pattern)
+}
+
+// isHidden is a helper function that reports whether the file matched by
+// the Glob pattern is hidden below the folder of the pattern, e.g. .git/HEAD
+// for **. Patterns naming a hidden file or folder, like .well-known/** or
+// .*, match hidden files explicitly.
+func isHidden(pattern, match string) bool {
+	for _, segment := range strings.Split(filepath.ToSlash(pattern), "/") {
+		if isHiddenName(segment) {
+			return false
+		}
+	}
+
+	rel, err := filepath.Rel(sourceRoot(pattern), match)
+	if err != nil {
+		return false
+	}
+	for _, name := range strings.Split(filepath.ToSlash(rel), "/") {
+		if isHiddenName(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// isHiddenName is a helper function that reports whether the file name is
+// hidden.
+func isHiddenName(name string) bool {
+	return strings.HasPrefix(name, ".") && name != "." && name != ".."
+}
+
+// gitignored is a helper function that removes the files ignored by the
+// .gitignore files in the folders of the files and their parent folders, up
+// to the working directory.
+func gitignored(files []string) ([]string, error) {
+	cache := map[string]*ignore.GitIgnore{}
+	load := func(dir string) (*ignore.GitIgnore, error) {
+		if gi, ok := cache[dir]; ok {
+			return gi, nil
+		}
+		var gi *ignore.GitIgnore
+		path := filepath.Join(dir, ".gitignore")
+		if _, err := os.Stat(path); err == nil {
+			if gi, err = ignore.CompileIgnoreFile(path); err != nil {
+				return nil, err
+			}
+		}
+		cache[dir] = gi
+		return gi, nil
+	}
+
+	var kept []string
+	for _, file := range files {
+		skip := false
+		rel := filepath.Clean(file)
+		// files outside of the working directory are not checked.
+		if !filepath.IsAbs(rel) && !strings.HasPrefix(rel, "..") {
+			for dir := filepath.Dir(rel); ; dir = filepath.Dir(dir) {
+				gi, err := load(dir)
+				if err != nil {
+					return nil, err
+				}
+				if gi != nil {
+					path, _ := filepath.Rel(dir, rel)
+					if gi.MatchesPath(filepath.ToSlash(path)) {
+						skip = true
+						break
+					}
+				}
+				if dir == "." {
+					break
+				}
+			}
+		}
+		if !skip {
+			kept = append(kept, file)
+		}
+	}
+	return kept, nil
+}
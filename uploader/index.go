@@ -0,0 +1,195 @@
+package uploader
+
+import (
+	"bytes"
+	"context"
+	"html/template"
+	"sort"
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// indexPage is the name of the generated directory index pages.
+const indexPage = "index.html"
+
+// indexTemplate renders the listing of a directory, like the autoindex pages
+// of web servers.
+var indexTemplate = template.Must(template.New("index").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Index of {{ .Path }}</title>
+</head>
+<body>
+<h1>Index of {{ .Path }}</h1>
+<table>
+<tr><th>Name</th><th>Last modified</th><th>Size</th></tr>
+{{- if .Parent }}
+<tr><td><a href="../">../</a></td><td></td><td></td></tr>
+{{- end }}
+{{- range .Entries }}
+<tr><td><a href="{{ .Href }}">{{ .Name }}</a></td><td>{{ .Modified }}</td><td>{{ .Size }}</td></tr>
+{{- end }}
+</table>
+</body>
+</html>
+`))
+
+// indexEntry is a file or subdirectory listed by a directory index page.
+type indexEntry struct {
+	Name     string
+	Href     string
+	Modified string
+	Size     string
+}
+
+// indexDirs returns the directories of the uploaded keys, including their
+// parent directories up to the target.
+func (o *Options) indexDirs(uploaded map[string]bool) []string {
+	root := strings.TrimPrefix(o.Target, "/")
+	if root != "" && !strings.HasSuffix(root, "/") {
+		root += "/"
+	}
+	if o.LeadingSlash {
+		root = "/" + root
+	}
+
+	dirs := map[string]bool{}
+	for key := range uploaded {
+		dir := key[:strings.LastIndex(key, "/")+1]
+		for !dirs[dir] {
+			dirs[dir] = true
+			if dir == root || !strings.HasPrefix(dir, root) || dir == "" {
+				break
+			}
+			dir = dir[:strings.LastIndex(strings.TrimSuffix(dir, "/"), "/")+1]
+		}
+	}
+
+	sorted := make([]string, 0, len(dirs))
+	for dir := range dirs {
+		sorted = append(sorted, dir)
+	}
+	sort.Strings(sorted)
+	return sorted
+}
+
+// generateIndexes uploads an index page listing the objects of each directory
+// of the uploaded keys, so the directories are browsable behind static
+// hosting. Directories with an uploaded index page are skipped.
+func (o *Options) generateIndexes(ctx context.Context, client S3API, uploader *manager.Uploader, uploads *manifest, uploaded map[string]bool) error {
+	for _, dir := range o.indexDirs(uploaded) {
+		target := dir + indexPage
+		if uploaded[target] {
+			continue
+		}
+		uploaded[target] = true
+
+		err := o.putGenerated(ctx, uploader, uploads, "index", target, "text/html; charset=utf-8", func() ([]byte, error) {
+			return o.indexPage(ctx, client, dir)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// putGenerated uploads a page generated by the plugin, rendering its content
+// unless executing a dry-run.
+func (o *Options) putGenerated(ctx context.Context, uploader *manager.Uploader, uploads *manifest, kind, target, contentType string, render func() ([]byte, error)) error {
+	log.WithFields(log.Fields{
+		"bucket": o.Bucket,
+		"target": target,
+	}).Info("Uploading " + kind)
+
+	input := &s3.PutObjectInput{
+		Bucket:       aws.String(o.Bucket),
+		Key:          aws.String(target),
+		ContentType:  aws.String(contentType),
+		CacheControl: aws.String("no-cache"),
+	}
+	o.applyACL(input)
+	if o.Encryption != "" {
+		input.ServerSideEncryption = types.ServerSideEncryption(o.Encryption)
+	}
+	if o.KMSKeyID != "" {
+		input.SSEKMSKeyId = aws.String(o.KMSKeyID)
+	}
+
+	// when executing a dry-run we exit because we don't actually want to
+	// upload the page to S3, only report the planned upload.
+	if o.DryRun {
+		return uploads.add("", input, nil)
+	}
+
+	body, err := render()
+	if err != nil {
+		return err
+	}
+	input.Body = bytes.NewReader(body)
+	output, err := uploader.Upload(ctx, input)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"bucket": o.Bucket,
+			"target": target,
+			"error":  err,
+		}).Error("Could not upload " + kind)
+		return err
+	}
+	return uploads.add("", input, output)
+}
+
+// indexPage renders the index page of the directory from the listing of the
+// objects and subdirectories in the bucket.
+func (o *Options) indexPage(ctx context.Context, client S3API, dir string) ([]byte, error) {
+	var dirs, files []indexEntry
+	paginator := s3.NewListObjectsV2Paginator(client, &s3.ListObjectsV2Input{
+		Bucket:    aws.String(o.Bucket),
+		Prefix:    aws.String(dir),
+		Delimiter: aws.String("/"),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"bucket": o.Bucket,
+				"prefix": dir,
+				"error":  err,
+			}).Error("Could not list remote objects")
+			return nil, err
+		}
+		for _, p := range page.CommonPrefixes {
+			name := strings.TrimPrefix(aws.ToString(p.Prefix), dir)
+			dirs = append(dirs, indexEntry{Name: name, Href: "./" + escapeKey(name), Size: "-"})
+		}
+		for _, object := range page.Contents {
+			name := strings.TrimPrefix(aws.ToString(object.Key), dir)
+			// skip the index page and folder placeholder objects
+			if name == indexPage || name == "" {
+				continue
+			}
+			files = append(files, indexEntry{
+				Name:     name,
+				Href:     "./" + escapeKey(name),
+				Modified: aws.ToTime(object.LastModified).UTC().Format(time.RFC3339),
+				Size:     formatSize(aws.ToInt64(object.Size)),
+			})
+		}
+	}
+
+	// the root of the bucket has no parent directory.
+	var buf bytes.Buffer
+	err := indexTemplate.Execute(&buf, map[string]interface{}{
+		"Path":    "/" + strings.TrimPrefix(dir, "/"),
+		"Parent":  strings.Trim(dir, "/") != "",
+		"Entries": append(dirs, files...),
+	})
+	return buf.Bytes(), err
+}
@@ -0,0 +1,73 @@
+package uploader
+
+import (
+	"compress/gzip"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"hash"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/andybalholm/brotli"
+)
+
+// integrityAlgorithms lists the hash algorithms of Subresource Integrity.
+var integrityAlgorithms = []string{"sha256", "sha384", "sha512"}
+
+// hasIntegrity is a helper function that reports whether the file is a script
+// or stylesheet, which pages reference with integrity attributes.
+func hasIntegrity(name string) bool {
+	switch strings.ToLower(path.Ext(trimCompressedExt(name))) {
+	case ".js", ".mjs", ".css":
+		return true
+	}
+	return false
+}
+
+// integrity is a helper function that returns the Subresource Integrity hash
+// of the file, like sha384-<base64 digest>. Browsers check the decoded
+// content, so files compressed by the build are decompressed first. Files of
+// content encodings that can't be decoded have no hash, as the hash is an
+// optional extra of the manifest.
+func integrity(algorithm, name, encoding string) (string, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	switch encoding {
+	case "":
+	case "gzip":
+		if r, err = gzip.NewReader(f); err != nil {
+			return "", err
+		}
+	case "br":
+		r = brotli.NewReader(f)
+	default:
+		log.WithFields(log.Fields{
+			"name":     name,
+			"encoding": encoding,
+		}).Warn("Skipping the integrity hash of an unsupported content encoding")
+		return "", nil
+	}
+
+	var h hash.Hash
+	switch algorithm {
+	case "sha256":
+		h = sha256.New()
+	case "sha384":
+		h = sha512.New384()
+	default:
+		h = sha512.New()
+	}
+	if _, err := io.Copy(h, r); err != nil {
+		return "", err
+	}
+	return algorithm + "-" + base64.StdEncoding.EncodeToString(h.Sum(nil)), nil
+}
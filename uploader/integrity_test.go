@@ -0,0 +1,84 @@
+package uploader
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha512"
+	"encoding/base64"
+	"io/ioutil"
+	"testing"
+)
+
+func TestIntegrity(t *testing.T) {
+	chdir(t, nil)
+
+	content := []byte("console.log('app')")
+	var gz bytes.Buffer
+	w := gzip.NewWriter(&gz)
+	w.Write(content)
+	w.Close()
+	for name, b := range map[string][]byte{"app.js": content, "app.js.gz": gz.Bytes(), "app.js.zst": []byte("zstd")} {
+		if err := ioutil.WriteFile(name, b, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sum := sha512.Sum384(content)
+	want := "sha384-" + base64.StdEncoding.EncodeToString(sum[:])
+
+	tests := []struct {
+		name, encoding, want string
+	}{
+		{"app.js", "", want},
+		{"app.js.gz", "gzip", want},
+		{"app.js.zst", "zstd", ""},
+		{"app.js.zst", "compress", ""},
+	}
+	for _, tt := range tests {
+		got, err := integrity("sha384", tt.name, tt.encoding)
+		if err != nil {
+			t.Errorf("integrity of %s with %q encoding failed: %s", tt.name, tt.encoding, err)
+		}
+		if got != tt.want {
+			t.Errorf("got integrity of %s with %q encoding %q, want %q", tt.name, tt.encoding, got, tt.want)
+		}
+	}
+}
+
+func TestUploadIntegrityUnknownEncoding(t *testing.T) {
+	chdir(t, map[string]string{
+		"app.js":     "console.log('app')",
+		"lib.js.zst": "zstd",
+	})
+
+	m := NewMemory("bucket")
+	report, err := Upload(context.Background(), Options{
+		Client:          m,
+		SkipPreflight:   true,
+		Bucket:          "bucket",
+		Source:          []string{"*.js", "*.zst"},
+		Integrity:       "sha384",
+		ContentEncoding: map[string]string{"*.zst": "zstd"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Files) != 2 {
+		t.Fatalf("got %d uploaded files, want 2", len(report.Files))
+	}
+	for _, f := range report.Files {
+		switch f.Key {
+		case "app.js":
+			if f.Integrity == "" {
+				t.Error("got no integrity hash of app.js")
+			}
+		case "lib.js.zst":
+			if f.Integrity != "" {
+				t.Errorf("got integrity hash %s of lib.js.zst", f.Integrity)
+			}
+		}
+	}
+	if m.Object("bucket", "lib.js.zst") == nil {
+		t.Error("lib.js.zst not uploaded")
+	}
+}
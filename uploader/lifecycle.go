@@ -0,0 +1,101 @@
+package uploader
+
+import (
+	"context"
+	"fmt"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// expireTag is the key of the tag holding the number of days after which the
+// uploaded objects expire, e.g. ttl=30d, matched by the lifecycle rules.
+const expireTag = "ttl"
+
+// tags returns the tags of the uploaded objects, including the expiry tag.
+func (o *Options) tags() map[string]string {
+	if o.ExpireAfter <= 0 {
+		return o.Tags
+	}
+
+	tags := map[string]string{}
+	for k, v := range o.Tags {
+		tags[k] = v
+	}
+	tags[expireTag] = expireValue(o.ExpireAfter)
+	return tags
+}
+
+// expireValue is a helper function that returns the value of the expiry tag.
+func expireValue(days int) string {
+	return fmt.Sprintf("%dd", days)
+}
+
+// lifecycleRule adds the lifecycle rule expiring the objects tagged with the
+// expiry of the uploads to the bucket lifecycle configuration, unless the
+// rule exists. The other rules of the bucket are kept.
+func (o *Options) lifecycleRule(ctx context.Context, client S3API) error {
+	value := expireValue(o.ExpireAfter)
+	rule := types.LifecycleRule{
+		ID:     aws.String("drone-s3-" + expireTag + "-" + value),
+		Status: types.ExpirationStatusEnabled,
+		Filter: &types.LifecycleRuleFilter{
+			Tag: &types.Tag{Key: aws.String(expireTag), Value: aws.String(value)},
+		},
+		Expiration: &types.LifecycleExpiration{
+			Days: aws.Int32(int32(o.ExpireAfter)),
+		},
+	}
+
+	// buckets without lifecycle configuration return a 404 response.
+	var rules []types.LifecycleRule
+	out, err := client.GetBucketLifecycleConfiguration(ctx, &s3.GetBucketLifecycleConfigurationInput{
+		Bucket: aws.String(o.Bucket),
+	})
+	if err != nil && !isNotFound(err) {
+		log.WithFields(log.Fields{
+			"bucket": o.Bucket,
+			"error":  err,
+		}).Error("Could not get the bucket lifecycle configuration")
+		return err
+	}
+	if err == nil {
+		rules = out.Rules
+	}
+
+	for _, r := range rules {
+		if aws.ToString(r.ID) == aws.ToString(rule.ID) {
+			return nil
+		}
+	}
+
+	log.WithFields(log.Fields{
+		"bucket": o.Bucket,
+		"rule":   aws.ToString(rule.ID),
+		"days":   o.ExpireAfter,
+	}).Info("Adding the lifecycle rule")
+
+	// when executing a dry-run we exit because we don't actually want to
+	// change the bucket configuration.
+	if o.DryRun {
+		return nil
+	}
+
+	_, err = client.PutBucketLifecycleConfiguration(ctx, &s3.PutBucketLifecycleConfigurationInput{
+		Bucket: aws.String(o.Bucket),
+		LifecycleConfiguration: &types.BucketLifecycleConfiguration{
+			Rules: append(rules, rule),
+		},
+	})
+	if err != nil {
+		log.WithFields(log.Fields{
+			"bucket": o.Bucket,
+			"rule":   aws.ToString(rule.ID),
+			"error":  err,
+		}).Error("Could not add the lifecycle rule")
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package uploader
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// listedFiles is the number of matched files listed when the source matches
+// too many files.
+const listedFiles = 10
+
+// checkLimits fails when the files matched by the sources of the mappings
+// exceed the maximum number of files or the maximum total size, e.g. because
+// of a source matching the whole workspace.
+func (o *Options) checkLimits(mappings []*Options) error {
+	var matched []string
+	for _, m := range mappings {
+		files, err := m.sourceFiles()
+		if err != nil {
+			return err
+		}
+		matched = append(matched, files...)
+	}
+
+	if o.MaxFiles > 0 && len(matched) > o.MaxFiles {
+		listed := matched
+		if len(listed) > listedFiles {
+			listed = listed[:listedFiles]
+		}
+		log.WithFields(log.Fields{
+			"files":     len(matched),
+			"max-files": o.MaxFiles,
+			"first":     strings.Join(listed, ","),
+		}).Error("Too many files matched")
+		return fmt.Errorf("refusing to upload %d files, more than the maximum of %d, first matched %s", len(matched), o.MaxFiles, strings.Join(listed, ", "))
+	}
+
+	if o.MaxTotalSize <= 0 {
+		return nil
+	}
+	var total int64
+	for _, name := range matched {
+		if stat, err := os.Stat(name); err == nil {
+			total += stat.Size()
+		}
+	}
+	if total > o.MaxTotalSize {
+		log.WithFields(log.Fields{
+			"files":          len(matched),
+			"size":           formatSize(total),
+			"max-total-size": formatSize(o.MaxTotalSize),
+		}).Error("Matched files exceed the maximum total size")
+		return fmt.Errorf("refusing to upload %d files of %s, more than the maximum total size of %s", len(matched), formatSize(total), formatSize(o.MaxTotalSize))
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package uploader
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// Mapping uploads the files matching the source pattern to the target, with
+// optional overrides of the step settings.
+type Mapping struct {
+	Source      Patterns `json:"source"`
+	Target      string   `json:"target"`
+	StripPrefix string   `json:"strip_prefix"`
+	Exclude     []string `json:"exclude"`
+	Access      string   `json:"acl"`
+}
+
+// mappings returns a copy of the options for each mapping, with the mapping
+// settings applied. Without mappings the options themselves are returned.
+func (o *Options) mappings() []*Options {
+	if len(o.Mappings) == 0 {
+		return []*Options{o}
+	}
+
+	mappings := make([]*Options, len(o.Mappings))
+	for i, m := range o.Mappings {
+		opts := *o
+		opts.Source = m.Source
+		if m.Target != "" {
+			opts.Target = m.Target
+		}
+		if m.StripPrefix != "" {
+			opts.StripPrefix = m.StripPrefix
+		}
+		if len(m.Exclude) != 0 {
+			opts.Exclude = m.Exclude
+		}
+		if m.Access != "" {
+			opts.Access = m.Access
+		}
+		mappings[i] = &opts
+	}
+	return mappings
+}
+
+// Patterns is a list of Glob patterns, decoded from either a JSON list or a
+// single string of patterns separated by commas.
+type Patterns []string
+
+// UnmarshalJSON decodes the patterns.
+func (p *Patterns) UnmarshalJSON(b []byte) error {
+	var value string
+	if err := json.Unmarshal(b, &value); err == nil {
+		*p = splitPatterns(value)
+		return nil
+	}
+
+	var list []string
+	if err := json.Unmarshal(b, &list); err != nil {
+		return err
+	}
+	*p = list
+	return nil
+}
+
+// splitPatterns is a helper function that splits the value on commas outside
+// of braces.
+func splitPatterns(value string) []string {
+	var (
+		patterns []string
+		depth    int
+		start    int
+	)
+	for i, r := range value {
+		switch r {
+		case '{':
+			depth++
+		case '}':
+			if depth > 0 {
+				depth--
+			}
+		case ',':
+			if depth == 0 {
+				patterns = appendPattern(patterns, value[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return appendPattern(patterns, value[start:])
+}
+
+// appendPattern is a helper function that appends the trimmed pattern unless
+// it is empty.
+func appendPattern(patterns []string, pattern string) []string {
+	if pattern = strings.TrimSpace(pattern); pattern != "" {
+		patterns = append(patterns, pattern)
+	}
+	return patterns
+}
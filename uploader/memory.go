@@ -0,0 +1,867 @@
+package uploader
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+// Memory is an in-memory S3 client, used to run the uploader without AWS.
+// The client options passed to the operations are ignored.
+type Memory struct {
+	// Fail returns the error of the operation on the key, or nil to
+	// execute the operation. The key is empty for bucket operations.
+	Fail func(operation, key string) error
+
+	mu         sync.Mutex
+	buckets    map[string]map[string]*MemoryObject
+	encryption map[string]*types.ServerSideEncryptionConfiguration
+	websites   map[string]*types.WebsiteConfiguration
+	cors       map[string]*types.CORSConfiguration
+	lifecycles map[string][]types.LifecycleRule
+	uploads    map[string]*memoryUpload
+	next       int
+}
+
+// MemoryObject is an object stored by the Memory client.
+type MemoryObject struct {
+	// Upload settings of the object, without the body.
+	Input *s3.PutObjectInput
+
+	Body         []byte
+	ETag         string
+	Checksum     string
+	LastModified time.Time
+
+	// Restore request of an archived object.
+	Restore *types.RestoreRequest
+}
+
+// memoryUpload is a multipart upload in progress.
+type memoryUpload struct {
+	input *s3.PutObjectInput
+	parts map[int32][]byte
+}
+
+// NewMemory creates an in-memory S3 client with the given buckets.
+func NewMemory(buckets ...string) *Memory {
+	m := &Memory{
+		buckets:    map[string]map[string]*MemoryObject{},
+		encryption: map[string]*types.ServerSideEncryptionConfiguration{},
+		websites:   map[string]*types.WebsiteConfiguration{},
+		cors:       map[string]*types.CORSConfiguration{},
+		lifecycles: map[string][]types.LifecycleRule{},
+		uploads:    map[string]*memoryUpload{},
+	}
+	for _, bucket := range buckets {
+		m.buckets[bucket] = map[string]*MemoryObject{}
+	}
+	return m
+}
+
+// Object returns the object stored under the key, or nil when missing.
+func (m *Memory) Object(bucket, key string) *MemoryObject {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.buckets[bucket][key]
+}
+
+// Lifecycle returns the lifecycle rules of the bucket.
+func (m *Memory) Lifecycle(bucket string) []types.LifecycleRule {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.lifecycles[bucket]
+}
+
+// WebsiteConfig returns the website configuration of the bucket.
+func (m *Memory) WebsiteConfig(bucket string) *types.WebsiteConfiguration {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.websites[bucket]
+}
+
+// CORS returns the CORS configuration of the bucket.
+func (m *Memory) CORS(bucket string) *types.CORSConfiguration {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.cors[bucket]
+}
+
+// Keys returns the sorted keys of the objects stored in the bucket.
+func (m *Memory) Keys(bucket string) []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.keys(bucket)
+}
+
+func (m *Memory) keys(bucket string) []string {
+	var keys []string
+	for key := range m.buckets[bucket] {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// fail is a helper function that returns the configured error of the
+// operation.
+func (m *Memory) fail(operation, key string) error {
+	if m.Fail == nil {
+		return nil
+	}
+	return m.Fail(operation, key)
+}
+
+// bucket is a helper function that returns the objects of the bucket, or a
+// NoSuchBucket error when missing.
+func (m *Memory) bucket(name *string) (map[string]*MemoryObject, error) {
+	if objects, ok := m.buckets[aws.ToString(name)]; ok {
+		return objects, nil
+	}
+	return nil, memoryError(http.StatusNotFound, &types.NoSuchBucket{Message: aws.String("The specified bucket does not exist")})
+}
+
+// store saves the object with the settings of the input.
+func (m *Memory) store(input *s3.PutObjectInput, body []byte, etag string) (*MemoryObject, error) {
+	objects, err := m.bucket(input.Bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	settings := *input
+	settings.Body = nil
+	object := &MemoryObject{
+		Input:        &settings,
+		Body:         body,
+		ETag:         `"` + etag + `"`,
+		LastModified: time.Now().UTC(),
+	}
+	objects[aws.ToString(input.Key)] = object
+	return object, nil
+}
+
+// precondition fails conditional writes of existing objects.
+func (m *Memory) precondition(bucket, key, ifNoneMatch *string) error {
+	if aws.ToString(ifNoneMatch) != "*" {
+		return nil
+	}
+	objects, err := m.bucket(bucket)
+	if err != nil {
+		return err
+	}
+	if _, ok := objects[aws.ToString(key)]; ok {
+		return memoryError(http.StatusPreconditionFailed, &smithy.GenericAPIError{Code: "PreconditionFailed", Message: "At least one of the pre-conditions you specified did not hold"})
+	}
+	return nil
+}
+
+// PutObject stores the object.
+func (m *Memory) PutObject(ctx context.Context, input *s3.PutObjectInput, _ ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	if err := m.fail("PutObject", aws.ToString(input.Key)); err != nil {
+		return nil, err
+	}
+
+	var body []byte
+	if input.Body != nil {
+		var err error
+		if body, err = ioutil.ReadAll(input.Body); err != nil {
+			return nil, err
+		}
+	}
+	sum := md5.Sum(body)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.precondition(input.Bucket, input.Key, input.IfNoneMatch); err != nil {
+		return nil, err
+	}
+	object, err := m.store(input, body, hex.EncodeToString(sum[:]))
+	if err != nil {
+		return nil, err
+	}
+
+	// compute the additional checksum of single part uploads.
+	if algorithm := string(input.ChecksumAlgorithm); algorithm != "" {
+		summer, err := newChecksum(algorithm)
+		if err != nil {
+			return nil, err
+		}
+		summer.Write(body)
+		object.Checksum = base64.StdEncoding.EncodeToString(summer.Sum(nil))
+	}
+	return &s3.PutObjectOutput{ETag: aws.String(object.ETag)}, nil
+}
+
+// CreateMultipartUpload starts a multipart upload of the object.
+func (m *Memory) CreateMultipartUpload(ctx context.Context, input *s3.CreateMultipartUploadInput, _ ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error) {
+	if err := m.fail("CreateMultipartUpload", aws.ToString(input.Key)); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, err := m.bucket(input.Bucket); err != nil {
+		return nil, err
+	}
+
+	// keep the settings shared with single part uploads.
+	settings := &s3.PutObjectInput{}
+	src, dst := reflect.ValueOf(input).Elem(), reflect.ValueOf(settings).Elem()
+	for i := 0; i < src.NumField(); i++ {
+		field := dst.FieldByName(src.Type().Field(i).Name)
+		if field.IsValid() && field.CanSet() && field.Type() == src.Field(i).Type() {
+			field.Set(src.Field(i))
+		}
+	}
+
+	m.next++
+	id := strconv.Itoa(m.next)
+	m.uploads[id] = &memoryUpload{
+		input: settings,
+		parts: map[int32][]byte{},
+	}
+	return &s3.CreateMultipartUploadOutput{
+		Bucket:   input.Bucket,
+		Key:      input.Key,
+		UploadId: aws.String(id),
+	}, nil
+}
+
+// UploadPart stores a part of a multipart upload.
+func (m *Memory) UploadPart(ctx context.Context, input *s3.UploadPartInput, _ ...func(*s3.Options)) (*s3.UploadPartOutput, error) {
+	if err := m.fail("UploadPart", aws.ToString(input.Key)); err != nil {
+		return nil, err
+	}
+
+	body, err := ioutil.ReadAll(input.Body)
+	if err != nil {
+		return nil, err
+	}
+	sum := md5.Sum(body)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	upload, err := m.upload(input.UploadId)
+	if err != nil {
+		return nil, err
+	}
+	upload.parts[aws.ToInt32(input.PartNumber)] = body
+	return &s3.UploadPartOutput{ETag: aws.String(`"` + hex.EncodeToString(sum[:]) + `"`)}, nil
+}
+
+// CompleteMultipartUpload stores the object from the uploaded parts.
+func (m *Memory) CompleteMultipartUpload(ctx context.Context, input *s3.CompleteMultipartUploadInput, _ ...func(*s3.Options)) (*s3.CompleteMultipartUploadOutput, error) {
+	if err := m.fail("CompleteMultipartUpload", aws.ToString(input.Key)); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	upload, err := m.upload(input.UploadId)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.precondition(input.Bucket, input.Key, input.IfNoneMatch); err != nil {
+		return nil, err
+	}
+
+	// the ETag of multipart objects is the MD5 of the part MD5s, followed by
+	// the number of parts.
+	var body, sums []byte
+	if input.MultipartUpload != nil {
+		for _, part := range input.MultipartUpload.Parts {
+			data, ok := upload.parts[aws.ToInt32(part.PartNumber)]
+			if !ok {
+				return nil, memoryError(http.StatusBadRequest, &types.InvalidRequest{Message: aws.String("missing part")})
+			}
+			sum := md5.Sum(data)
+			body = append(body, data...)
+			sums = append(sums, sum[:]...)
+		}
+	}
+	sum := md5.Sum(sums)
+	etag := fmt.Sprintf("%s-%d", hex.EncodeToString(sum[:]), len(upload.parts))
+
+	object, err := m.store(upload.input, body, etag)
+	if err != nil {
+		return nil, err
+	}
+	delete(m.uploads, aws.ToString(input.UploadId))
+	return &s3.CompleteMultipartUploadOutput{
+		Bucket: input.Bucket,
+		Key:    input.Key,
+		ETag:   aws.String(object.ETag),
+	}, nil
+}
+
+// AbortMultipartUpload discards a multipart upload.
+func (m *Memory) AbortMultipartUpload(ctx context.Context, input *s3.AbortMultipartUploadInput, _ ...func(*s3.Options)) (*s3.AbortMultipartUploadOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.uploads, aws.ToString(input.UploadId))
+	return &s3.AbortMultipartUploadOutput{}, nil
+}
+
+// upload is a helper function that returns the multipart upload, or a
+// NoSuchUpload error when missing.
+func (m *Memory) upload(id *string) (*memoryUpload, error) {
+	if upload, ok := m.uploads[aws.ToString(id)]; ok {
+		return upload, nil
+	}
+	return nil, memoryError(http.StatusNotFound, &types.NoSuchUpload{Message: aws.String("The specified upload does not exist")})
+}
+
+// object is a helper function that returns the object, or a NotFound error
+// when missing.
+func (m *Memory) object(bucket, key *string) (*MemoryObject, error) {
+	objects, err := m.bucket(bucket)
+	if err != nil {
+		return nil, err
+	}
+	if object, ok := objects[aws.ToString(key)]; ok {
+		return object, nil
+	}
+	return nil, memoryError(http.StatusNotFound, &types.NoSuchKey{Message: aws.String("The specified key does not exist")})
+}
+
+// HeadObject returns the settings of the object.
+func (m *Memory) HeadObject(ctx context.Context, input *s3.HeadObjectInput, _ ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+	if err := m.fail("HeadObject", aws.ToString(input.Key)); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	object, err := m.object(input.Bucket, input.Key)
+	if err != nil {
+		return nil, err
+	}
+
+	settings := object.Input
+	head := &s3.HeadObjectOutput{
+		ContentLength:      aws.Int64(int64(len(object.Body))),
+		ContentType:        settings.ContentType,
+		ContentEncoding:    settings.ContentEncoding,
+		ContentDisposition: settings.ContentDisposition,
+		CacheControl:       settings.CacheControl,
+		ETag:               aws.String(object.ETag),
+		LastModified:       aws.Time(object.LastModified),
+		Metadata:           settings.Metadata,
+		StorageClass:       settings.StorageClass,
+
+		ServerSideEncryption: settings.ServerSideEncryption,
+		SSEKMSKeyId:          settings.SSEKMSKeyId,
+		SSECustomerAlgorithm: settings.SSECustomerAlgorithm,
+	}
+	if input.ChecksumMode == types.ChecksumModeEnabled && object.Checksum != "" {
+		switch settings.ChecksumAlgorithm {
+		case types.ChecksumAlgorithmCrc32:
+			head.ChecksumCRC32 = aws.String(object.Checksum)
+		case types.ChecksumAlgorithmCrc32c:
+			head.ChecksumCRC32C = aws.String(object.Checksum)
+		case types.ChecksumAlgorithmSha1:
+			head.ChecksumSHA1 = aws.String(object.Checksum)
+		case types.ChecksumAlgorithmSha256:
+			head.ChecksumSHA256 = aws.String(object.Checksum)
+		}
+	}
+	return head, nil
+}
+
+// PresignGetObject returns a memory:// URL of the object, with the expiry
+// of the presign options.
+func (m *Memory) PresignGetObject(ctx context.Context, input *s3.GetObjectInput, optFns ...func(*s3.PresignOptions)) (*v4.PresignedHTTPRequest, error) {
+	if err := m.fail("PresignGetObject", aws.ToString(input.Key)); err != nil {
+		return nil, err
+	}
+
+	var opts s3.PresignOptions
+	for _, fn := range optFns {
+		fn(&opts)
+	}
+	query := url.Values{"X-Amz-Expires": {strconv.Itoa(int(opts.Expires.Seconds()))}}
+	if input.VersionId != nil {
+		query.Set("versionId", aws.ToString(input.VersionId))
+	}
+	if input.RequestPayer != "" {
+		query.Set("x-amz-request-payer", string(input.RequestPayer))
+	}
+	u := &url.URL{
+		Scheme:   "memory",
+		Host:     aws.ToString(input.Bucket),
+		Path:     "/" + aws.ToString(input.Key),
+		RawQuery: query.Encode(),
+	}
+	return &v4.PresignedHTTPRequest{
+		URL:          u.String(),
+		Method:       http.MethodGet,
+		SignedHeader: http.Header{},
+	}, nil
+}
+
+// GetObject returns the content of the object, or the requested range.
+func (m *Memory) GetObject(ctx context.Context, input *s3.GetObjectInput, _ ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	if err := m.fail("GetObject", aws.ToString(input.Key)); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	object, err := m.object(input.Bucket, input.Key)
+	if err != nil {
+		return nil, err
+	}
+
+	body, size := object.Body, int64(len(object.Body))
+	out := &s3.GetObjectOutput{
+		ContentType:  object.Input.ContentType,
+		ETag:         aws.String(object.ETag),
+		LastModified: aws.Time(object.LastModified),
+		Metadata:     object.Input.Metadata,
+	}
+	if r := aws.ToString(input.Range); r != "" {
+		var start, end int64
+		if _, err := fmt.Sscanf(r, "bytes=%d-%d", &start, &end); err != nil || start > end || start >= size {
+			return nil, memoryError(http.StatusRequestedRangeNotSatisfiable, &types.InvalidRequest{Message: aws.String("The requested range is not satisfiable")})
+		}
+		if end >= size {
+			end = size - 1
+		}
+		body = body[start : end+1]
+		out.ContentRange = aws.String(fmt.Sprintf("bytes %d-%d/%d", start, end, size))
+	}
+	out.Body = ioutil.NopCloser(bytes.NewReader(body))
+	out.ContentLength = aws.Int64(int64(len(body)))
+	return out, nil
+}
+
+// CopyObject copies the object of the copy source, keeping its settings
+// unless the metadata directive replaces them.
+func (m *Memory) CopyObject(ctx context.Context, input *s3.CopyObjectInput, _ ...func(*s3.Options)) (*s3.CopyObjectOutput, error) {
+	if err := m.fail("CopyObject", aws.ToString(input.Key)); err != nil {
+		return nil, err
+	}
+
+	bucket, key, err := memoryCopySource(input.CopySource)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	object, err := m.object(bucket, key)
+	if err != nil {
+		return nil, err
+	}
+
+	settings := *object.Input
+	if input.MetadataDirective == types.MetadataDirectiveReplace {
+		settings.ContentType = input.ContentType
+		settings.ContentEncoding = input.ContentEncoding
+		settings.ContentDisposition = input.ContentDisposition
+		settings.CacheControl = input.CacheControl
+		settings.Metadata = input.Metadata
+	}
+	settings.Bucket, settings.Key = input.Bucket, input.Key
+	settings.ACL = input.ACL
+	settings.StorageClass = input.StorageClass
+	settings.ServerSideEncryption = input.ServerSideEncryption
+	settings.SSEKMSKeyId = input.SSEKMSKeyId
+
+	copied, err := m.store(&settings, object.Body, strings.Trim(object.ETag, `"`))
+	if err != nil {
+		return nil, err
+	}
+	copied.Checksum = object.Checksum
+	return &s3.CopyObjectOutput{
+		CopyObjectResult: &types.CopyObjectResult{
+			ETag:         aws.String(copied.ETag),
+			LastModified: aws.Time(copied.LastModified),
+		},
+	}, nil
+}
+
+// UploadPartCopy stores the byte range of the copy source as a part of a
+// multipart upload.
+func (m *Memory) UploadPartCopy(ctx context.Context, input *s3.UploadPartCopyInput, _ ...func(*s3.Options)) (*s3.UploadPartCopyOutput, error) {
+	if err := m.fail("UploadPartCopy", aws.ToString(input.Key)); err != nil {
+		return nil, err
+	}
+
+	bucket, key, err := memoryCopySource(input.CopySource)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	object, err := m.object(bucket, key)
+	if err != nil {
+		return nil, err
+	}
+	upload, err := m.upload(input.UploadId)
+	if err != nil {
+		return nil, err
+	}
+
+	body := object.Body
+	if r := aws.ToString(input.CopySourceRange); r != "" {
+		var start, end int
+		if _, err := fmt.Sscanf(r, "bytes=%d-%d", &start, &end); err != nil || start > end || end >= len(body) {
+			return nil, memoryError(http.StatusBadRequest, &types.InvalidRequest{Message: aws.String("invalid copy source range")})
+		}
+		body = body[start : end+1]
+	}
+	body = append([]byte(nil), body...)
+	upload.parts[aws.ToInt32(input.PartNumber)] = body
+
+	sum := md5.Sum(body)
+	return &s3.UploadPartCopyOutput{
+		CopyPartResult: &types.CopyPartResult{
+			ETag:         aws.String(`"` + hex.EncodeToString(sum[:]) + `"`),
+			LastModified: aws.Time(time.Now()),
+		},
+	}, nil
+}
+
+// GetObjectTagging returns the tags the object was stored with.
+func (m *Memory) GetObjectTagging(ctx context.Context, input *s3.GetObjectTaggingInput, _ ...func(*s3.Options)) (*s3.GetObjectTaggingOutput, error) {
+	if err := m.fail("GetObjectTagging", aws.ToString(input.Key)); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	object, err := m.object(input.Bucket, input.Key)
+	if err != nil {
+		return nil, err
+	}
+
+	values, err := url.ParseQuery(aws.ToString(object.Input.Tagging))
+	if err != nil {
+		return nil, memoryError(http.StatusBadRequest, err)
+	}
+	out := &s3.GetObjectTaggingOutput{TagSet: []types.Tag{}}
+	for k := range values {
+		out.TagSet = append(out.TagSet, types.Tag{Key: aws.String(k), Value: aws.String(values.Get(k))})
+	}
+	sort.Slice(out.TagSet, func(i, j int) bool {
+		return aws.ToString(out.TagSet[i].Key) < aws.ToString(out.TagSet[j].Key)
+	})
+	return out, nil
+}
+
+// memoryCopySource is a helper function that returns the bucket and key of
+// the URL encoded copy source.
+func memoryCopySource(copySource *string) (*string, *string, error) {
+	source, err := url.PathUnescape(strings.TrimPrefix(aws.ToString(copySource), "/"))
+	if err != nil {
+		return nil, nil, memoryError(http.StatusBadRequest, err)
+	}
+	i := strings.Index(source, "/")
+	if i == -1 {
+		return nil, nil, memoryError(http.StatusBadRequest, fmt.Errorf("invalid copy source %s", source))
+	}
+	return aws.String(source[:i]), aws.String(source[i+1:]), nil
+}
+
+// RestoreObject records the restore request of an archived object, failing
+// for objects that aren't archived or are already being restored.
+func (m *Memory) RestoreObject(ctx context.Context, input *s3.RestoreObjectInput, _ ...func(*s3.Options)) (*s3.RestoreObjectOutput, error) {
+	if err := m.fail("RestoreObject", aws.ToString(input.Key)); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	object, err := m.object(input.Bucket, input.Key)
+	if err != nil {
+		return nil, err
+	}
+
+	switch object.Input.StorageClass {
+	case types.StorageClassGlacier, types.StorageClassDeepArchive:
+	default:
+		return nil, memoryError(http.StatusForbidden, &types.ObjectAlreadyInActiveTierError{Message: aws.String("Restore is not allowed for the object's current storage class")})
+	}
+	if object.Restore != nil {
+		return nil, memoryError(http.StatusConflict, &smithy.GenericAPIError{Code: "RestoreAlreadyInProgress", Message: "Object restore is already in progress"})
+	}
+	object.Restore = input.RestoreRequest
+	return &s3.RestoreObjectOutput{}, nil
+}
+
+// PutBucketWebsite replaces the website configuration of the bucket.
+func (m *Memory) PutBucketWebsite(ctx context.Context, input *s3.PutBucketWebsiteInput, _ ...func(*s3.Options)) (*s3.PutBucketWebsiteOutput, error) {
+	if err := m.fail("PutBucketWebsite", ""); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, err := m.bucket(input.Bucket); err != nil {
+		return nil, err
+	}
+	m.websites[aws.ToString(input.Bucket)] = input.WebsiteConfiguration
+	return &s3.PutBucketWebsiteOutput{}, nil
+}
+
+// PutBucketCors replaces the CORS configuration of the bucket.
+func (m *Memory) PutBucketCors(ctx context.Context, input *s3.PutBucketCorsInput, _ ...func(*s3.Options)) (*s3.PutBucketCorsOutput, error) {
+	if err := m.fail("PutBucketCors", ""); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, err := m.bucket(input.Bucket); err != nil {
+		return nil, err
+	}
+	m.cors[aws.ToString(input.Bucket)] = input.CORSConfiguration
+	return &s3.PutBucketCorsOutput{}, nil
+}
+
+// GetBucketEncryption returns the default encryption of the bucket, or a
+// ServerSideEncryptionConfigurationNotFoundError error when it has none.
+func (m *Memory) GetBucketEncryption(ctx context.Context, input *s3.GetBucketEncryptionInput, _ ...func(*s3.Options)) (*s3.GetBucketEncryptionOutput, error) {
+	if err := m.fail("GetBucketEncryption", ""); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, err := m.bucket(input.Bucket); err != nil {
+		return nil, err
+	}
+	config, ok := m.encryption[aws.ToString(input.Bucket)]
+	if !ok {
+		return nil, memoryError(http.StatusNotFound, &smithy.GenericAPIError{Code: "ServerSideEncryptionConfigurationNotFoundError", Message: "The server side encryption configuration was not found"})
+	}
+	return &s3.GetBucketEncryptionOutput{ServerSideEncryptionConfiguration: config}, nil
+}
+
+// PutBucketEncryption sets the default encryption of the bucket.
+func (m *Memory) PutBucketEncryption(ctx context.Context, input *s3.PutBucketEncryptionInput, _ ...func(*s3.Options)) (*s3.PutBucketEncryptionOutput, error) {
+	if err := m.fail("PutBucketEncryption", ""); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, err := m.bucket(input.Bucket); err != nil {
+		return nil, err
+	}
+	m.encryption[aws.ToString(input.Bucket)] = input.ServerSideEncryptionConfiguration
+	return &s3.PutBucketEncryptionOutput{}, nil
+}
+
+// GetBucketLifecycleConfiguration returns the lifecycle rules of the
+// bucket, or a NoSuchLifecycleConfiguration error when it has none.
+func (m *Memory) GetBucketLifecycleConfiguration(ctx context.Context, input *s3.GetBucketLifecycleConfigurationInput, _ ...func(*s3.Options)) (*s3.GetBucketLifecycleConfigurationOutput, error) {
+	if err := m.fail("GetBucketLifecycleConfiguration", ""); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, err := m.bucket(input.Bucket); err != nil {
+		return nil, err
+	}
+	rules, ok := m.lifecycles[aws.ToString(input.Bucket)]
+	if !ok {
+		return nil, memoryError(http.StatusNotFound, &smithy.GenericAPIError{Code: "NoSuchLifecycleConfiguration", Message: "The lifecycle configuration does not exist"})
+	}
+	return &s3.GetBucketLifecycleConfigurationOutput{Rules: rules}, nil
+}
+
+// PutBucketLifecycleConfiguration replaces the lifecycle rules of the
+// bucket.
+func (m *Memory) PutBucketLifecycleConfiguration(ctx context.Context, input *s3.PutBucketLifecycleConfigurationInput, _ ...func(*s3.Options)) (*s3.PutBucketLifecycleConfigurationOutput, error) {
+	if err := m.fail("PutBucketLifecycleConfiguration", ""); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, err := m.bucket(input.Bucket); err != nil {
+		return nil, err
+	}
+	var rules []types.LifecycleRule
+	if input.LifecycleConfiguration != nil {
+		rules = append(rules, input.LifecycleConfiguration.Rules...)
+	}
+	m.lifecycles[aws.ToString(input.Bucket)] = rules
+	return &s3.PutBucketLifecycleConfigurationOutput{}, nil
+}
+
+// HeadBucket checks the bucket exists.
+func (m *Memory) HeadBucket(ctx context.Context, input *s3.HeadBucketInput, _ ...func(*s3.Options)) (*s3.HeadBucketOutput, error) {
+	if err := m.fail("HeadBucket", ""); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.buckets[aws.ToString(input.Bucket)]; !ok {
+		return nil, memoryError(http.StatusNotFound, &types.NotFound{})
+	}
+	return &s3.HeadBucketOutput{}, nil
+}
+
+// CreateBucket creates the bucket.
+func (m *Memory) CreateBucket(ctx context.Context, input *s3.CreateBucketInput, _ ...func(*s3.Options)) (*s3.CreateBucketOutput, error) {
+	if err := m.fail("CreateBucket", ""); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	bucket := aws.ToString(input.Bucket)
+	if _, ok := m.buckets[bucket]; ok {
+		return nil, memoryError(http.StatusConflict, &types.BucketAlreadyOwnedByYou{Message: aws.String("The bucket already exists")})
+	}
+	m.buckets[bucket] = map[string]*MemoryObject{}
+	return &s3.CreateBucketOutput{Location: aws.String("/" + bucket)}, nil
+}
+
+// ListObjectsV2 lists the objects with keys starting with the prefix, in
+// pages of at most MaxKeys objects. The keys containing the delimiter after
+// the prefix are grouped into common prefixes.
+func (m *Memory) ListObjectsV2(ctx context.Context, input *s3.ListObjectsV2Input, _ ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+	if err := m.fail("ListObjectsV2", aws.ToString(input.Prefix)); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	objects, err := m.bucket(input.Bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	limit := int(aws.ToInt32(input.MaxKeys))
+	if limit <= 0 {
+		limit = 1000
+	}
+	after := aws.ToString(input.StartAfter)
+	if token := aws.ToString(input.ContinuationToken); token != "" {
+		after = token
+	}
+
+	prefix, delimiter := aws.ToString(input.Prefix), aws.ToString(input.Delimiter)
+	out := &s3.ListObjectsV2Output{
+		Name:      input.Bucket,
+		Prefix:    input.Prefix,
+		Delimiter: input.Delimiter,
+	}
+	var last string
+	for _, key := range m.keys(aws.ToString(input.Bucket)) {
+		if key <= after || !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		// the keys of a common prefix listed on a previous page sort after
+		// the prefix.
+		if delimiter != "" && strings.HasSuffix(after, delimiter) && strings.HasPrefix(key, after) {
+			continue
+		}
+
+		common := ""
+		if i := strings.Index(key[len(prefix):], delimiter); delimiter != "" && i != -1 {
+			common = key[:len(prefix)+i+len(delimiter)]
+			if common == last {
+				continue
+			}
+		}
+		if len(out.Contents)+len(out.CommonPrefixes) == limit {
+			out.IsTruncated = aws.Bool(true)
+			out.NextContinuationToken = aws.String(last)
+			break
+		}
+		if common != "" {
+			out.CommonPrefixes = append(out.CommonPrefixes, types.CommonPrefix{Prefix: aws.String(common)})
+			last = common
+			continue
+		}
+		last = key
+
+		object := objects[key]
+		out.Contents = append(out.Contents, types.Object{
+			Key:          aws.String(key),
+			Size:         aws.Int64(int64(len(object.Body))),
+			ETag:         aws.String(object.ETag),
+			LastModified: aws.Time(object.LastModified),
+			StorageClass: types.ObjectStorageClass(object.Input.StorageClass),
+		})
+	}
+	out.KeyCount = aws.Int32(int32(len(out.Contents) + len(out.CommonPrefixes)))
+	return out, nil
+}
+
+// DeleteObjects removes the objects, reporting the configured failures per
+// key.
+func (m *Memory) DeleteObjects(ctx context.Context, input *s3.DeleteObjectsInput, _ ...func(*s3.Options)) (*s3.DeleteObjectsOutput, error) {
+	if err := m.fail("DeleteObjects", ""); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	objects, err := m.bucket(input.Bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	out := &s3.DeleteObjectsOutput{}
+	if input.Delete == nil {
+		return out, nil
+	}
+	for _, id := range input.Delete.Objects {
+		key := aws.ToString(id.Key)
+		if err := m.fail("DeleteObject", key); err != nil {
+			out.Errors = append(out.Errors, types.Error{
+				Key:     id.Key,
+				Code:    aws.String("InternalError"),
+				Message: aws.String(err.Error()),
+			})
+			continue
+		}
+		delete(objects, key)
+		out.Deleted = append(out.Deleted, types.DeletedObject{Key: id.Key})
+	}
+	return out, nil
+}
+
+// memoryError is a helper function that returns the error as a response
+// error with the HTTP status code, as returned by the S3 client.
+func memoryError(status int, err error) error {
+	return &awshttp.ResponseError{
+		ResponseError: &smithyhttp.ResponseError{
+			Response: &smithyhttp.Response{Response: &http.Response{
+				StatusCode: status,
+				Header:     http.Header{},
+				Body:       http.NoBody,
+			}},
+			Err: err,
+		},
+	}
+}
@@ -0,0 +1,180 @@
+package uploader
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// copy size limits of S3. Objects larger than a single copy are copied in
+// parts of at least the copy part size, within the maximum number of parts.
+var (
+	maxCopySize  int64 = 5 << 30
+	copyPartSize int64 = 512 << 20
+)
+
+// copyParts copies the object of the copy input in parts, for objects larger
+// than a single copy. Unlike a single copy, the content settings, metadata
+// and tags of the source object are applied explicitly.
+func (o *Options) copyParts(ctx context.Context, client S3API, input *s3.CopyObjectInput, bucket, key string, head *s3.HeadObjectOutput) error {
+	tagging, err := client.GetObjectTagging(ctx, &s3.GetObjectTaggingInput{
+		Bucket:    aws.String(bucket),
+		Key:       aws.String(key),
+		VersionId: head.VersionId,
+	})
+	if err != nil {
+		return err
+	}
+	tags := map[string]string{}
+	for _, tag := range tagging.TagSet {
+		tags[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+	}
+
+	create := &s3.CreateMultipartUploadInput{
+		Bucket:                    input.Bucket,
+		Key:                       input.Key,
+		ACL:                       input.ACL,
+		GrantRead:                 input.GrantRead,
+		GrantReadACP:              input.GrantReadACP,
+		GrantWriteACP:             input.GrantWriteACP,
+		GrantFullControl:          input.GrantFullControl,
+		ServerSideEncryption:      input.ServerSideEncryption,
+		SSEKMSKeyId:               input.SSEKMSKeyId,
+		SSECustomerAlgorithm:      input.SSECustomerAlgorithm,
+		SSECustomerKey:            input.SSECustomerKey,
+		SSECustomerKeyMD5:         input.SSECustomerKeyMD5,
+		StorageClass:              input.StorageClass,
+		ObjectLockMode:            input.ObjectLockMode,
+		ObjectLockRetainUntilDate: input.ObjectLockRetainUntilDate,
+		ContentType:               head.ContentType,
+		ContentEncoding:           head.ContentEncoding,
+		ContentDisposition:        head.ContentDisposition,
+		ContentLanguage:           head.ContentLanguage,
+		CacheControl:              head.CacheControl,
+		Expires:                   head.Expires,
+		WebsiteRedirectLocation:   head.WebsiteRedirectLocation,
+		Metadata:                  head.Metadata,
+	}
+	if len(tags) != 0 {
+		create.Tagging = aws.String(encodeTags(tags))
+	}
+
+	upload, err := client.CreateMultipartUpload(ctx, create)
+	if err != nil {
+		return err
+	}
+
+	parts, err := o.copyPartsOf(ctx, client, input, upload.UploadId, aws.ToInt64(head.ContentLength))
+	if err != nil {
+		// the parts copied so far are discarded, the error of the copy is
+		// returned.
+		client.AbortMultipartUpload(context.Background(), &s3.AbortMultipartUploadInput{
+			Bucket:   input.Bucket,
+			Key:      input.Key,
+			UploadId: upload.UploadId,
+		})
+		return err
+	}
+
+	_, err = client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:               input.Bucket,
+		Key:                  input.Key,
+		UploadId:             upload.UploadId,
+		MultipartUpload:      &types.CompletedMultipartUpload{Parts: parts},
+		SSECustomerAlgorithm: input.SSECustomerAlgorithm,
+		SSECustomerKey:       input.SSECustomerKey,
+		SSECustomerKeyMD5:    input.SSECustomerKeyMD5,
+	})
+	return err
+}
+
+// copyPartsOf copies the byte ranges of the source object into the parts of
+// the multipart upload, returning the completed parts in order.
+func (o *Options) copyPartsOf(ctx context.Context, client S3API, input *s3.CopyObjectInput, uploadID *string, size int64) ([]types.CompletedPart, error) {
+	partSize := copyPartSize
+	if min := (size + int64(manager.MaxUploadParts) - 1) / int64(manager.MaxUploadParts); partSize < min {
+		partSize = min
+	}
+	concurrency := o.PartConcurrency
+	if concurrency < 1 {
+		concurrency = manager.DefaultUploadConcurrency
+	}
+
+	type part struct {
+		number     int32
+		start, end int64
+	}
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		errs   []error
+		parts  []types.CompletedPart
+		queued = make(chan part)
+	)
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for p := range queued {
+				out, err := client.UploadPartCopy(ctx, &s3.UploadPartCopyInput{
+					Bucket:                         input.Bucket,
+					Key:                            input.Key,
+					UploadId:                       uploadID,
+					PartNumber:                     aws.Int32(p.number),
+					CopySource:                     input.CopySource,
+					CopySourceRange:                aws.String(fmt.Sprintf("bytes=%d-%d", p.start, p.end)),
+					CopySourceSSECustomerAlgorithm: input.CopySourceSSECustomerAlgorithm,
+					CopySourceSSECustomerKey:       input.CopySourceSSECustomerKey,
+					CopySourceSSECustomerKeyMD5:    input.CopySourceSSECustomerKeyMD5,
+					SSECustomerAlgorithm:           input.SSECustomerAlgorithm,
+					SSECustomerKey:                 input.SSECustomerKey,
+					SSECustomerKeyMD5:              input.SSECustomerKeyMD5,
+				})
+				mu.Lock()
+				if err != nil {
+					errs = append(errs, err)
+				} else {
+					parts = append(parts, types.CompletedPart{
+						ETag:       out.CopyPartResult.ETag,
+						PartNumber: aws.Int32(p.number),
+					})
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for number, start := int32(1), int64(0); start < size; number, start = number+1, start+partSize {
+		mu.Lock()
+		failed := len(errs) != 0
+		mu.Unlock()
+		if failed || ctx.Err() != nil {
+			break
+		}
+
+		end := start + partSize - 1
+		if end >= size {
+			end = size - 1
+		}
+		queued <- part{number: number, start: start, end: end}
+	}
+	close(queued)
+	wg.Wait()
+
+	if len(errs) != 0 {
+		return nil, errs[0]
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	sort.Slice(parts, func(i, j int) bool {
+		return aws.ToInt32(parts[i].PartNumber) < aws.ToInt32(parts[j].PartNumber)
+	})
+	return parts, nil
+}
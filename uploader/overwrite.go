@@ -0,0 +1,110 @@
+package uploader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// overwrite policies of the existing objects.
+const (
+	overwriteAlways      = "always"
+	overwriteNever       = "never"
+	overwriteIfNewer     = "if-newer"
+	overwriteIfDifferent = "if-different"
+)
+
+var overwritePolicies = []string{overwriteAlways, overwriteNever, overwriteIfNewer, overwriteIfDifferent}
+
+// overwritePolicy returns the overwrite policy of the existing objects,
+// defaulting to always unless only uploading changed files.
+func (o *Options) overwritePolicy() string {
+	switch {
+	case o.Overwrite != "":
+		return o.Overwrite
+	case o.OnlyChanged:
+		return overwriteIfDifferent
+	default:
+		return overwriteAlways
+	}
+}
+
+// skipExisting reports whether the existing object is kept according to the
+// overwrite policy, failing when objects are never overwritten.
+func (o *Options) skipExisting(ctx context.Context, client S3API, match, target, compress string) (bool, error) {
+	policy := o.overwritePolicy()
+	if policy == overwriteAlways {
+		return false, nil
+	}
+
+	head, err := o.headObject(ctx, client, target)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"name":   match,
+			"bucket": o.Bucket,
+			"target": target,
+			"error":  err,
+		}).Error("Could not compare file")
+		return false, err
+	}
+	if head == nil {
+		return false, nil
+	}
+
+	var skip bool
+	switch policy {
+	case overwriteNever:
+		log.WithFields(log.Fields{
+			"name":   match,
+			"bucket": o.Bucket,
+			"target": target,
+		}).Error("Object already exists")
+		return false, fmt.Errorf("object %s already exists in bucket %s, objects are never overwritten", target, o.Bucket)
+	case overwriteIfNewer:
+		skip, err = notNewer(head, match, compress)
+	default:
+		skip, err = o.sameContent(head, match, compress)
+	}
+	if err != nil {
+		log.WithFields(log.Fields{
+			"name":   match,
+			"bucket": o.Bucket,
+			"target": target,
+			"error":  err,
+		}).Error("Could not compare file")
+	}
+	return skip, err
+}
+
+// notNewer is a helper function that reports whether the local file wasn't
+// modified since the remote object was uploaded. Files compressed while
+// uploading are compared by modification time only, otherwise the object
+// must have the size of the file too.
+func notNewer(head *s3.HeadObjectOutput, match, compress string) (bool, error) {
+	info, err := os.Stat(match)
+	if err != nil {
+		return false, err
+	}
+	if info.ModTime().After(aws.ToTime(head.LastModified)) {
+		return false, nil
+	}
+	return compress != "" || info.Size() == aws.ToInt64(head.ContentLength), nil
+}
+
+// isPreconditionFailed is a helper function that reports whether a
+// conditional write failed because the object exists, or because a
+// concurrent write of the object is in progress.
+func isPreconditionFailed(err error) bool {
+	var respErr *awshttp.ResponseError
+	if !errors.As(err, &respErr) {
+		return false
+	}
+	return respErr.HTTPStatusCode() == http.StatusPreconditionFailed || respErr.HTTPStatusCode() == http.StatusConflict
+}
@@ -0,0 +1,111 @@
+package uploader
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// preflightKey is the name of the empty object uploaded by the write probe.
+const preflightKey = ".drone-s3-preflight"
+
+// preflight checks the credentials can access the bucket before uploading any
+// file, optionally uploading and deleting an empty object under the target
+// to check the write permission.
+func (o *Options) preflight(ctx context.Context, client S3API) error {
+	_, err := client.HeadBucket(ctx, &s3.HeadBucketInput{
+		Bucket: aws.String(o.Bucket),
+	})
+	if err != nil {
+		err = o.preflightError(err, "access")
+		log.WithFields(log.Fields{
+			"bucket": o.Bucket,
+			"region": o.Region,
+			"error":  err,
+		}).Error("Could not access the bucket")
+		return err
+	}
+
+	if !o.PreflightProbe {
+		return nil
+	}
+
+	key := strings.TrimPrefix(path.Join(o.Target, preflightKey), "/")
+	if o.LeadingSlash {
+		key = "/" + key
+	}
+	input := &s3.PutObjectInput{
+		Bucket: aws.String(o.Bucket),
+		Key:    aws.String(key),
+		Body:   bytes.NewReader(nil),
+	}
+	o.applyACL(input)
+	if o.Encryption != "" {
+		input.ServerSideEncryption = types.ServerSideEncryption(o.Encryption)
+	}
+	if o.KMSKeyID != "" {
+		input.SSEKMSKeyId = aws.String(o.KMSKeyID)
+	}
+	if o.SSECustomerKey != "" {
+		input.SSECustomerKey, input.SSECustomerKeyMD5 = sseCustomerKey(o.SSECustomerKey)
+		input.SSECustomerAlgorithm = aws.String(o.SSECustomerAlgorithm)
+	}
+
+	if _, err := client.PutObject(ctx, input); err != nil {
+		err = o.preflightError(err, "write")
+		log.WithFields(log.Fields{
+			"bucket": o.Bucket,
+			"region": o.Region,
+			"target": key,
+			"error":  err,
+		}).Error("Could not write to the bucket")
+		return err
+	}
+
+	// the probe object is only removed on a best effort basis, failing to
+	// delete it doesn't affect the upload.
+	_, err = client.DeleteObjects(ctx, &s3.DeleteObjectsInput{
+		Bucket: aws.String(o.Bucket),
+		Delete: &types.Delete{
+			Objects: []types.ObjectIdentifier{{Key: aws.String(key)}},
+			Quiet:   aws.Bool(true),
+		},
+	})
+	if err != nil {
+		log.WithFields(log.Fields{
+			"bucket": o.Bucket,
+			"target": key,
+			"error":  err,
+		}).Warn("Could not delete the preflight object")
+	}
+	return nil
+}
+
+// preflightError is a helper function that returns an actionable error for
+// the failed preflight request.
+func (o *Options) preflightError(err error, access string) error {
+	var respErr *awshttp.ResponseError
+	if !errors.As(err, &respErr) {
+		return err
+	}
+
+	switch respErr.HTTPStatusCode() {
+	case http.StatusForbidden:
+		return fmt.Errorf("%s denied to bucket %s in region %s, check the credentials and the bucket policy: %s", access, o.Bucket, o.Region, err)
+	case http.StatusNotFound:
+		return fmt.Errorf("bucket %s does not exist in region %s: %s", o.Bucket, o.Region, err)
+	case http.StatusMovedPermanently:
+		return fmt.Errorf("bucket %s is not in region %s, set the region of the bucket: %s", o.Bucket, o.Region, err)
+	}
+	return err
+}
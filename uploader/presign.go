@@ -0,0 +1,90 @@
+package uploader
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// defaultPresignExpires is the default validity of the presigned URLs.
+const defaultPresignExpires = 24 * time.Hour
+
+// maxPresignExpires is the maximum validity of URLs presigned with SigV4.
+const maxPresignExpires = 7 * 24 * time.Hour
+
+// presigner presigns the download requests of the objects, implemented by
+// the S3 presign client and the Memory client.
+type presigner interface {
+	PresignGetObject(context.Context, *s3.GetObjectInput, ...func(*s3.PresignOptions)) (*v4.PresignedHTTPRequest, error)
+}
+
+// presign adds the presigned download URLs of the uploaded files to the
+// report. The URLs are signed locally, without any request to S3.
+func (o *Options) presign(ctx context.Context, client S3API, report *Report) error {
+	var p presigner
+	switch c := client.(type) {
+	case *s3.Client:
+		// the presigned URLs are sent to the primary endpoint without the
+		// middleware of the uploads, e.g. the failover and request headers.
+		p = s3.NewPresignClient(c, s3.WithPresignClientFromClientOptions(func(opts *s3.Options) {
+			opts.APIOptions = nil
+			opts.EndpointResolverV2 = s3.NewDefaultEndpointResolverV2()
+		}))
+	case presigner:
+		p = c
+	default:
+		return errors.New("the client can't presign URLs")
+	}
+
+	expires := o.PresignExpires
+	if expires <= 0 {
+		expires = defaultPresignExpires
+	}
+
+	for i, f := range report.Files {
+		// objects without local file, e.g. redirects, have no content to
+		// download.
+		if f.Name == "" {
+			continue
+		}
+
+		input := &s3.GetObjectInput{
+			Bucket: aws.String(o.Bucket),
+			Key:    aws.String(f.Key),
+		}
+		if f.VersionID != "" {
+			input.VersionId = aws.String(f.VersionID)
+		}
+		// the request headers of the client aren't sent with the presigned
+		// URLs, so the requester pays the download as part of the signature.
+		if o.RequesterPays {
+			input.RequestPayer = types.RequestPayerRequester
+		}
+		req, err := p.PresignGetObject(ctx, input, s3.WithPresignExpires(expires))
+		if err != nil {
+			log.WithFields(log.Fields{
+				"name":   f.Name,
+				"bucket": o.Bucket,
+				"target": f.Key,
+				"error":  err,
+			}).Error("Could not presign the file URL")
+			return err
+		}
+		report.Files[i].PresignedURL = req.URL
+
+		// the signatures of the URL are redacted from the log output, so the
+		// URL is only written to the manifest.
+		log.WithFields(log.Fields{
+			"name":    f.Name,
+			"target":  f.Key,
+			"expires": expires.String(),
+		}).Info("Presigned file URL")
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package uploader
+
+import (
+	"context"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+func TestPresign(t *testing.T) {
+	// the URLs are signed locally, so the real client needs no endpoint.
+	client := s3.NewFromConfig(aws.Config{
+		Region:      "us-east-1",
+		Credentials: credentials.NewStaticCredentialsProvider("AKIAEXAMPLE", "secret", ""),
+	})
+
+	tests := []struct {
+		name          string
+		requesterPays bool
+		payer         string
+	}{
+		{"default", false, ""},
+		{"requester pays", true, "requester"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := &Options{
+				Bucket:         "bucket",
+				Presign:        true,
+				PresignExpires: time.Hour,
+				RequesterPays:  tt.requesterPays,
+			}
+			report := &Report{Files: []File{
+				{Name: "dist/index.html", Key: "index.html"},
+				{Key: "redirect"},
+			}}
+			if err := o.presign(context.Background(), client, report); err != nil {
+				t.Fatal(err)
+			}
+
+			u, err := url.Parse(report.Files[0].PresignedURL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			query := u.Query()
+			if got := query.Get("X-Amz-Expires"); got != "3600" {
+				t.Errorf("got expiry %q, want 3600", got)
+			}
+			if query.Get("X-Amz-Signature") == "" {
+				t.Errorf("got unsigned URL %s", u)
+			}
+			if got := query.Get("x-amz-request-payer"); got != tt.payer {
+				t.Errorf("got request payer %q, want %q", got, tt.payer)
+			}
+			if report.Files[1].PresignedURL != "" {
+				t.Errorf("got presigned URL of the redirect %s", report.Files[1].PresignedURL)
+			}
+		})
+	}
+}
+
+func TestPresignManifest(t *testing.T) {
+	o := &Options{Bucket: "bucket", Presign: true}
+	if err := o.validate(); err == nil {
+		t.Error("got no error presigning without manifest")
+	}
+	o.Manifest = "manifest.json"
+	if err := o.validate(); err != nil {
+		t.Errorf("got error presigning with manifest: %s", err)
+	}
+}
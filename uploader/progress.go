@@ -0,0 +1,179 @@
+package uploader
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync/atomic"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/smithy-go/middleware"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+// progress tracks the number of files and bytes uploaded. The bytes are the
+// bytes of the source files, also when the files are compressed while
+// uploading, so the transferred bytes add up to the total.
+type progress struct {
+	start time.Time
+
+	files       int64
+	bytes       int64
+	uploaded    int64
+	skipped     int64
+	failed      int64
+	transferred int64
+}
+
+// newProgress returns the progress of uploads starting now.
+func newProgress() *progress {
+	return &progress{start: time.Now()}
+}
+
+// add adds the files to the total number and size of files to upload.
+func (pr *progress) add(files []string) {
+	for _, file := range files {
+		if stat, err := os.Stat(file); err == nil {
+			atomic.AddInt64(&pr.bytes, stat.Size())
+		}
+	}
+	atomic.AddInt64(&pr.files, int64(len(files)))
+}
+
+// skip counts the file as skipped, removing it from the total size of files
+// to upload.
+func (pr *progress) skip(file string) {
+	if stat, err := os.Stat(file); err == nil {
+		atomic.AddInt64(&pr.bytes, -stat.Size())
+	}
+	atomic.AddInt64(&pr.skipped, 1)
+}
+
+// attempt counts the source bytes transferred by a single upload attempt of
+// a file, so the bytes of failed attempts can be taken back.
+type attempt struct {
+	progress *progress
+	n        int64
+	// ratio of the source bytes to the bytes of the requests, or zero when
+	// the requests aren't counted, e.g. as the reads of the source file are.
+	ratio float64
+}
+
+// attemptKey is the context key of the upload attempt of the requests.
+type attemptKey struct{}
+
+// attempt starts counting an upload attempt of a file, returning the context
+// of the requests of the attempt.
+func (pr *progress) attempt(ctx context.Context, ratio float64) (context.Context, *attempt) {
+	a := &attempt{progress: pr, ratio: ratio}
+	return context.WithValue(ctx, attemptKey{}, a), a
+}
+
+// add adds the source bytes to the bytes transferred.
+func (a *attempt) add(n int64) {
+	atomic.AddInt64(&a.n, n)
+	atomic.AddInt64(&a.progress.transferred, n)
+}
+
+// Write counts the bytes read from the source file.
+func (a *attempt) Write(b []byte) (int, error) {
+	a.add(int64(len(b)))
+	return len(b), nil
+}
+
+// done settles the bytes of the attempt, which are the size of the file when
+// the upload succeeded and none when it failed.
+func (a *attempt) done(size int64, err error) {
+	if err != nil {
+		size = 0
+	}
+	a.add(size - atomic.LoadInt64(&a.n))
+}
+
+// clientOption returns a client option counting the bytes of the successful
+// PutObject and UploadPart requests of the upload attempts.
+func (pr *progress) clientOption() func(*s3.Options) {
+	count := middleware.DeserializeMiddlewareFunc("UploadProgress", func(ctx context.Context, in middleware.DeserializeInput, next middleware.DeserializeHandler) (middleware.DeserializeOutput, middleware.Metadata, error) {
+		out, metadata, err := next.HandleDeserialize(ctx, in)
+		if err != nil {
+			return out, metadata, err
+		}
+		a, ok := ctx.Value(attemptKey{}).(*attempt)
+		if !ok || a.ratio == 0 {
+			return out, metadata, nil
+		}
+		switch middleware.GetOperationName(ctx) {
+		case "PutObject", "UploadPart":
+			if req, ok := in.Request.(*smithyhttp.Request); ok && req.ContentLength > 0 {
+				a.add(int64(float64(req.ContentLength) * a.ratio))
+			}
+		}
+		return out, metadata, nil
+	})
+
+	return func(opts *s3.Options) {
+		opts.APIOptions = append(opts.APIOptions, func(stack *middleware.Stack) error {
+			return stack.Deserialize.Add(count, middleware.Before)
+		})
+	}
+}
+
+// report logs the progress at the interval until done is closed.
+func (pr *progress) report(interval time.Duration, done <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			pr.log()
+		}
+	}
+}
+
+// log logs the files and bytes uploaded so far, the transfer rate and the
+// estimated remaining time.
+func (pr *progress) log() {
+	var (
+		files       = atomic.LoadInt64(&pr.files)
+		bytes       = atomic.LoadInt64(&pr.bytes)
+		completed   = atomic.LoadInt64(&pr.uploaded) + atomic.LoadInt64(&pr.skipped) + atomic.LoadInt64(&pr.failed)
+		transferred = atomic.LoadInt64(&pr.transferred)
+		rate        = pr.rate(transferred)
+	)
+
+	fields := log.Fields{
+		"files": fmt.Sprintf("%d/%d", completed, files),
+		"bytes": fmt.Sprintf("%s/%s", formatSize(transferred), formatSize(bytes)),
+		"rate":  fmt.Sprintf("%.2f MB/s", rate/(1<<20)),
+	}
+	if rate > 0 && bytes > transferred {
+		fields["eta"] = time.Duration(float64(bytes-transferred) / rate * float64(time.Second)).Round(time.Second).String()
+	}
+	log.WithFields(fields).Info("Upload progress")
+}
+
+// summary logs the statistics of the finished uploads.
+func (pr *progress) summary() {
+	transferred := atomic.LoadInt64(&pr.transferred)
+	log.WithFields(log.Fields{
+		"uploaded": atomic.LoadInt64(&pr.uploaded),
+		"skipped":  atomic.LoadInt64(&pr.skipped),
+		"failed":   atomic.LoadInt64(&pr.failed),
+		"bytes":    formatSize(transferred),
+		"duration": time.Since(pr.start).Round(time.Millisecond).String(),
+		"rate":     fmt.Sprintf("%.2f MB/s", pr.rate(transferred)/(1<<20)),
+	}).Info("Upload statistics")
+}
+
+// rate returns the transfer rate in bytes per second.
+func (pr *progress) rate(transferred int64) float64 {
+	elapsed := time.Since(pr.start).Seconds()
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(transferred) / elapsed
+}
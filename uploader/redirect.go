@@ -0,0 +1,112 @@
+package uploader
+
+import (
+	"bytes"
+	"context"
+	"path"
+	"sort"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// redirects returns the redirect locations keyed by object key, those of the
+// options and in website mode those of the folders with an index page.
+func (o *Options) redirects(uploaded map[string]bool) map[string]string {
+	redirects := map[string]string{}
+
+	// redirect the folder keys without trailing slash to the folder, which
+	// the website endpoint serves with the index page.
+	if o.Website {
+		for key := range uploaded {
+			folder := strings.TrimSuffix(key, "/index.html")
+			if folder == key || strings.Trim(folder, "/") == "" || uploaded[folder] {
+				continue
+			}
+			redirects[folder] = "/" + strings.TrimPrefix(folder, "/") + "/"
+		}
+	}
+
+	for key, location := range o.Redirects {
+		target := strings.TrimPrefix(path.Join(o.Target, key), "/")
+		if strings.HasSuffix(key, "/") {
+			target += "/"
+		}
+		if o.LeadingSlash {
+			target = "/" + target
+		}
+		redirects[target] = location
+	}
+	return redirects
+}
+
+// redirect uploads an empty object for each redirect, which the S3 website
+// endpoint answers with a redirect to the location.
+func (o *Options) redirect(ctx context.Context, uploader *manager.Uploader, uploads *manifest, uploaded map[string]bool, redirects map[string]string) error {
+	var keys []string
+	for key := range redirects {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, target := range keys {
+		location := redirects[target]
+		uploaded[target] = true
+
+		input := &s3.PutObjectInput{
+			Bucket:                  aws.String(o.Bucket),
+			Key:                     aws.String(target),
+			WebsiteRedirectLocation: aws.String(location),
+		}
+		o.applyACL(input)
+		if o.Encryption != "" {
+			input.ServerSideEncryption = types.ServerSideEncryption(o.Encryption)
+		}
+		if o.KMSKeyID != "" {
+			input.SSEKMSKeyId = aws.String(o.KMSKeyID)
+		}
+
+		log.WithFields(log.Fields{
+			"bucket":   o.Bucket,
+			"target":   target,
+			"location": location,
+		}).Info("Uploading redirect")
+
+		// when executing a dry-run we exit because we don't actually want to
+		// upload the redirect to S3, only report the planned upload.
+		if o.DryRun {
+			if err := uploads.add("", input, nil); err != nil {
+				return err
+			}
+			continue
+		}
+
+		input.Body = bytes.NewReader(nil)
+		output, err := uploader.Upload(ctx, input)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"bucket":   o.Bucket,
+				"target":   target,
+				"location": location,
+				"error":    err,
+			}).Error("Could not upload redirect")
+			return err
+		}
+		if err := uploads.add("", input, output); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validRedirect is a helper function that reports whether S3 accepts the
+// redirect location, which is either a path or an absolute URL.
+func validRedirect(location string) bool {
+	return strings.HasPrefix(location, "/") ||
+		strings.HasPrefix(location, "http://") ||
+		strings.HasPrefix(location, "https://")
+}
@@ -0,0 +1,111 @@
+package uploader
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// remoteManifestName is the name of the object describing the uploaded files
+// of the target in the bucket.
+const remoteManifestName = ".drone-s3-manifest.json"
+
+// remoteManifestKey returns the key of the remote manifest of the target.
+func (o *Options) remoteManifestKey() string {
+	key := o.targetPrefix() + remoteManifestName
+	if o.LeadingSlash {
+		key = "/" + key
+	}
+	return key
+}
+
+// loadRemoteManifest reads the content hashes of the objects uploaded by the
+// previous runs from the remote manifest. A missing manifest returns an empty
+// state, so all files are uploaded.
+func (o *Options) loadRemoteManifest(ctx context.Context, client S3API) (*uploadState, error) {
+	key := o.remoteManifestKey()
+	input := &s3.GetObjectInput{
+		Bucket: aws.String(o.Bucket),
+		Key:    aws.String(key),
+	}
+	if o.SSECustomerKey != "" {
+		input.SSECustomerKey, input.SSECustomerKeyMD5 = sseCustomerKey(o.SSECustomerKey)
+		input.SSECustomerAlgorithm = aws.String(o.SSECustomerAlgorithm)
+	}
+
+	var b []byte
+	out, err := client.GetObject(ctx, input)
+	if err == nil {
+		b, err = ioutil.ReadAll(out.Body)
+		out.Body.Close()
+	}
+	if err != nil && !isNotFound(err) {
+		log.WithFields(log.Fields{
+			"bucket": o.Bucket,
+			"target": key,
+			"error":  err,
+		}).Error("Could not read the remote manifest")
+		return nil, err
+	}
+
+	state, err := newState(b)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"bucket": o.Bucket,
+			"target": key,
+			"error":  err,
+		}).Error("Could not read the remote manifest")
+		return nil, err
+	}
+	return state, nil
+}
+
+// saveRemoteManifest replaces the remote manifest with the objects of the
+// run. The manifest is written with a single request, so the following runs
+// read either the previous or the updated manifest.
+func (o *Options) saveRemoteManifest(ctx context.Context, client S3API) error {
+	key := o.remoteManifestKey()
+	body, err := o.state.encode()
+	if err != nil {
+		return err
+	}
+
+	// the manifest isn't served, so the ACL of the uploads isn't applied.
+	input := &s3.PutObjectInput{
+		Bucket:       aws.String(o.Bucket),
+		Key:          aws.String(key),
+		Body:         bytes.NewReader(body),
+		ContentType:  aws.String("application/json"),
+		CacheControl: aws.String("no-cache"),
+	}
+	if o.Encryption != "" {
+		input.ServerSideEncryption = types.ServerSideEncryption(o.Encryption)
+	}
+	if o.KMSKeyID != "" {
+		input.SSEKMSKeyId = aws.String(o.KMSKeyID)
+	}
+	if o.SSECustomerKey != "" {
+		input.SSECustomerKey, input.SSECustomerKeyMD5 = sseCustomerKey(o.SSECustomerKey)
+		input.SSECustomerAlgorithm = aws.String(o.SSECustomerAlgorithm)
+	}
+
+	log.WithFields(log.Fields{
+		"bucket": o.Bucket,
+		"target": key,
+	}).Info("Updating the remote manifest")
+
+	if _, err := client.PutObject(ctx, input); err != nil {
+		log.WithFields(log.Fields{
+			"bucket": o.Bucket,
+			"target": key,
+			"error":  err,
+		}).Error("Could not update the remote manifest")
+		return err
+	}
+	return nil
+}
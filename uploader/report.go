@@ -0,0 +1,244 @@
+package uploader
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"text/tabwriter"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// Report lists the uploaded files, or the files planned to be uploaded by a
+// dry-run, sorted by key.
+type Report struct {
+	// Bucket the files are uploaded to.
+	Bucket string
+	Files  []File
+
+	// Number of files uploaded, skipped as unchanged and failed to upload.
+	Uploaded int
+	Skipped  int
+	Failed   int
+	// Number of bytes transferred and duration of the uploads.
+	Bytes    int64
+	Duration time.Duration
+
+	// Keys of the fingerprinted files relative to the target, keyed by the
+	// keys without hash.
+	Fingerprints map[string]string
+
+	// Files that failed to upload, in the order the uploads failed.
+	Failures []Failure
+
+	// Reports of the additional destinations.
+	Destinations []Report
+}
+
+// File is a single uploaded file.
+type File struct {
+	Name         string            `json:"name"`
+	Key          string            `json:"key"`
+	Size         int64             `json:"size"`
+	ContentType  string            `json:"content_type"`
+	ACL          string            `json:"acl"`
+	Headers      map[string]string `json:"headers,omitempty"`
+	ETag         string            `json:"etag,omitempty"`
+	VersionID    string            `json:"version_id,omitempty"`
+	URL          string            `json:"url,omitempty"`
+	Integrity    string            `json:"integrity,omitempty"`
+	PublicURL    string            `json:"public_url,omitempty"`
+	PresignedURL string            `json:"presigned_url,omitempty"`
+}
+
+// Failure is a single file that failed to upload.
+type Failure struct {
+	Name  string `json:"name"`
+	Key   string `json:"key"`
+	Error string `json:"error"`
+}
+
+// manifest collects the uploaded files, or the files planned to be uploaded
+// by a dry-run.
+type manifest struct {
+	mu           sync.Mutex
+	entries      []File
+	failures     []Failure
+	fingerprints map[string]string
+	hashes       map[string]string
+}
+
+// failed records the failed upload of the local file.
+func (m *manifest) failed(match, key string, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.failures = append(m.failures, Failure{Name: match, Key: key, Error: err.Error()})
+}
+
+// integrity records the Subresource Integrity hash of the uploaded key.
+func (m *manifest) integrity(key, hash string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.hashes == nil {
+		m.hashes = map[string]string{}
+	}
+	m.hashes[key] = hash
+}
+
+// fingerprint records the rename of a fingerprinted file.
+func (m *manifest) fingerprint(key, renamed string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.fingerprints == nil {
+		m.fingerprints = map[string]string{}
+	}
+	m.fingerprints[key] = renamed
+}
+
+// add records the upload of the local file with the given input, or of an
+// object without local file when match is empty. The output is nil for
+// uploads planned by a dry-run.
+func (m *manifest) add(match string, input *s3.PutObjectInput, output *manager.UploadOutput) error {
+	var size int64
+	if match != "" {
+		stat, err := os.Stat(match)
+		if err != nil {
+			return err
+		}
+		size = stat.Size()
+	}
+
+	entry := File{
+		Name:        match,
+		Key:         aws.ToString(input.Key),
+		Size:        size,
+		ContentType: aws.ToString(input.ContentType),
+		ACL:         string(input.ACL),
+		Headers:     map[string]string{},
+	}
+	header := func(name, value string) {
+		if value != "" {
+			entry.Headers[name] = value
+		}
+	}
+	header("Cache-Control", aws.ToString(input.CacheControl))
+	header("Content-Encoding", aws.ToString(input.ContentEncoding))
+	if input.Expires != nil {
+		header("Expires", input.Expires.UTC().Format(http.TimeFormat))
+	}
+	header("X-Amz-Storage-Class", string(input.StorageClass))
+	header("X-Amz-Server-Side-Encryption", string(input.ServerSideEncryption))
+	header("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id", aws.ToString(input.SSEKMSKeyId))
+	header("X-Amz-Server-Side-Encryption-Customer-Algorithm", aws.ToString(input.SSECustomerAlgorithm))
+	header("X-Amz-Tagging", aws.ToString(input.Tagging))
+	header("X-Amz-Object-Lock-Mode", string(input.ObjectLockMode))
+	if input.ObjectLockRetainUntilDate != nil {
+		header("X-Amz-Object-Lock-Retain-Until-Date", input.ObjectLockRetainUntilDate.UTC().Format(time.RFC3339))
+	}
+	header("X-Amz-Grant-Read", aws.ToString(input.GrantRead))
+	header("X-Amz-Grant-Read-Acp", aws.ToString(input.GrantReadACP))
+	header("X-Amz-Grant-Write-Acp", aws.ToString(input.GrantWriteACP))
+	header("X-Amz-Grant-Full-Control", aws.ToString(input.GrantFullControl))
+	header("X-Amz-Website-Redirect-Location", aws.ToString(input.WebsiteRedirectLocation))
+	for name, value := range input.Metadata {
+		header("X-Amz-Meta-"+name, value)
+	}
+
+	if output != nil {
+		entry.ETag = strings.Trim(aws.ToString(output.ETag), `"`)
+		entry.VersionID = aws.ToString(output.VersionID)
+		entry.URL = output.Location
+	}
+
+	m.mu.Lock()
+	m.entries = append(m.entries, entry)
+	m.mu.Unlock()
+	return nil
+}
+
+// newReport is a helper function that returns the report of the collected
+// files and the progress.
+func newReport(uploads *manifest, progress *progress) Report {
+	files := append([]File(nil), uploads.entries...)
+	for i := range files {
+		files[i].Integrity = uploads.hashes[files[i].Key]
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Key < files[j].Key
+	})
+
+	return Report{
+		Files:        files,
+		Uploaded:     int(atomic.LoadInt64(&progress.uploaded)),
+		Skipped:      int(atomic.LoadInt64(&progress.skipped)),
+		Failed:       int(atomic.LoadInt64(&progress.failed)),
+		Bytes:        atomic.LoadInt64(&progress.transferred),
+		Duration:     time.Since(progress.start),
+		Fingerprints: uploads.fingerprints,
+		Failures:     uploads.failures,
+	}
+}
+
+// WriteTable prints the files as a table.
+func (r Report) WriteTable(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "KEY\tSIZE\tCONTENT-TYPE\tACL\tHEADERS")
+	for _, file := range r.Files {
+		names := make([]string, 0, len(file.Headers))
+		for name := range file.Headers {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		headers := make([]string, len(names))
+		for i, name := range names {
+			headers[i] = name + "=" + file.Headers[name]
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%s\t%s\n", file.Key, file.Size, file.ContentType, file.ACL, strings.Join(headers, " "))
+	}
+	return tw.Flush()
+}
+
+// WriteFailures prints the files that failed to upload to the bucket and the
+// destinations as a table.
+func (r Report) WriteFailures(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "BUCKET\tKEY\tNAME\tERROR")
+	for _, report := range append([]Report{r}, r.Destinations...) {
+		for _, failure := range report.Failures {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", report.Bucket, failure.Key, failure.Name, failure.Error)
+		}
+	}
+	return tw.Flush()
+}
+
+// WriteFile writes the files to the file as JSON.
+func (r Report) WriteFile(path string) error {
+	files := r.Files
+	if files == nil {
+		files = []File{}
+	}
+	out, err := json.MarshalIndent(files, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(path, append(out, '\n'), 0644); err != nil {
+		log.WithFields(log.Fields{
+			"file":  path,
+			"error": err,
+		}).Error("Could not write the report")
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package uploader
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+)
+
+// Restore requests the restore of the archived objects matching the source
+// patterns, e.g. objects in the Glacier storage classes, so the objects can
+// be downloaded once restored. Restoring takes minutes to hours depending on
+// the tier, the objects are not waited for.
+func Restore(ctx context.Context, opts Options) error {
+	if err := opts.validate(); err != nil {
+		return err
+	}
+
+	// bound the whole run by the global timeout
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+
+	_, client, err := opts.connect(ctx)
+	if err != nil {
+		return err
+	}
+	return opts.restore(ctx, client)
+}
+
+// restore requests the restore of the matching objects. Objects that aren't
+// archived or are already being restored are skipped.
+func (o *Options) restore(ctx context.Context, client S3API) error {
+	log.WithFields(log.Fields{
+		"region":   o.Region,
+		"endpoint": o.Endpoint,
+		"bucket":   o.Bucket,
+	}).Info("Attempting to restore")
+
+	keys, err := o.matchSources(ctx, client)
+	if err != nil {
+		return err
+	}
+
+	days := o.RestoreDays
+	if days < 1 {
+		days = 1
+	}
+	request := &types.RestoreRequest{
+		Days: aws.Int32(int32(days)),
+	}
+	if o.RestoreTier != "" {
+		request.GlacierJobParameters = &types.GlacierJobParameters{
+			Tier: types.Tier(o.RestoreTier),
+		}
+	}
+
+	for _, key := range keys {
+		// skip folder placeholder objects
+		if strings.HasSuffix(key, "/") {
+			continue
+		}
+
+		// log file for debug purposes.
+		log.WithFields(log.Fields{
+			"name":   key,
+			"bucket": o.Bucket,
+			"days":   days,
+			"tier":   o.RestoreTier,
+		}).Info("Restoring object")
+
+		// when executing a dry-run we exit because we don't actually want to
+		// restore the object.
+		if o.DryRun {
+			continue
+		}
+
+		_, err := client.RestoreObject(ctx, &s3.RestoreObjectInput{
+			Bucket:         aws.String(o.Bucket),
+			Key:            aws.String(key),
+			RestoreRequest: request,
+		})
+
+		var (
+			activeErr *types.ObjectAlreadyInActiveTierError
+			apiErr    smithy.APIError
+		)
+		switch {
+		case err == nil:
+		case errors.As(err, &activeErr):
+			log.WithFields(log.Fields{
+				"name":   key,
+				"bucket": o.Bucket,
+			}).Info("Skipping object that isn't archived")
+		case errors.As(err, &apiErr) && apiErr.ErrorCode() == "RestoreAlreadyInProgress":
+			log.WithFields(log.Fields{
+				"name":   key,
+				"bucket": o.Bucket,
+			}).Info("Skipping object already being restored")
+		default:
+			log.WithFields(log.Fields{
+				"name":   key,
+				"bucket": o.Bucket,
+				"error":  err,
+			}).Error("Could not restore object")
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,105 @@
+package uploader
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// retain deletes the oldest builds stored next to the target, keeping the
+// configured number of builds and the builds newer than the configured number
+// of days. Each folder in the parent folder of the target is a build, aged by
+// its most recently modified object. The build of the target is always kept.
+func (o *Options) retain(ctx context.Context, client S3API) error {
+	target := strings.Trim(o.Target, "/")
+	parent := path.Dir(target)
+	if target == "" || parent == "." {
+		return fmt.Errorf("retaining builds requires a target below a folder, e.g. builds/{{ .BuildNumber }}")
+	}
+
+	prefix := parent + "/"
+	if o.LeadingSlash {
+		prefix = "/" + prefix
+	}
+
+	type build struct {
+		name     string
+		modified time.Time
+		keys     []string
+	}
+
+	builds := map[string]*build{}
+	paginator := s3.NewListObjectsV2Paginator(client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(o.Bucket),
+		Prefix: aws.String(prefix),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"bucket": o.Bucket,
+				"prefix": prefix,
+				"error":  err,
+			}).Error("Could not list remote objects")
+			return err
+		}
+		for _, object := range page.Contents {
+			key := aws.ToString(object.Key)
+			parts := strings.SplitN(strings.TrimPrefix(key, prefix), "/", 2)
+			// objects stored next to the builds are not part of a build.
+			if len(parts) != 2 {
+				continue
+			}
+
+			b, ok := builds[parts[0]]
+			if !ok {
+				b = &build{name: parts[0]}
+				builds[parts[0]] = b
+			}
+			if modified := aws.ToTime(object.LastModified); modified.After(b.modified) {
+				b.modified = modified
+			}
+			b.keys = append(b.keys, key)
+		}
+	}
+
+	sorted := make([]*build, 0, len(builds))
+	for _, b := range builds {
+		sorted = append(sorted, b)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].modified.After(sorted[j].modified)
+	})
+
+	cutoff := time.Now().AddDate(0, 0, -o.RetainDays)
+	current := path.Base(target)
+
+	var (
+		keys []string
+		kept int
+	)
+	for _, b := range sorted {
+		expired := o.RetainBuilds > 0 && kept >= o.RetainBuilds ||
+			o.RetainDays > 0 && b.modified.Before(cutoff)
+		if b.name == current || !expired {
+			kept++
+			continue
+		}
+
+		log.WithFields(log.Fields{
+			"bucket":   o.Bucket,
+			"build":    prefix + b.name,
+			"modified": b.modified,
+		}).Info("Removing build")
+		keys = append(keys, b.keys...)
+	}
+
+	return o.delete(ctx, client, keys)
+}
@@ -0,0 +1,40 @@
+package uploader
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// Rewrite renames the object keys matching the regular expression pattern,
+// replacing the match with the replacement. The replacement can reference the
+// capture groups of the pattern, e.g. $1
+type Rewrite struct {
+	Pattern     string `json:"pattern"`
+	Replacement string `json:"replacement"`
+
+	re *regexp.Regexp
+}
+
+// compileRewrites is a helper function that compiles the patterns of the
+// rewrite rules.
+func compileRewrites(rewrites []Rewrite) ([]Rewrite, error) {
+	compiled := make([]Rewrite, len(rewrites))
+	for i, rewrite := range rewrites {
+		re, err := regexp.Compile(rewrite.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rewrite pattern %q: %s", rewrite.Pattern, err)
+		}
+		rewrite.re = re
+		compiled[i] = rewrite
+	}
+	return compiled, nil
+}
+
+// rewriteKey is a helper function that applies the rewrite rules to the key
+// in order, each rule applying to the key rewritten by the previous rules.
+func rewriteKey(key string, rewrites []Rewrite) string {
+	for _, rewrite := range rewrites {
+		key = rewrite.re.ReplaceAllString(key, rewrite.Replacement)
+	}
+	return key
+}
@@ -0,0 +1,44 @@
+package uploader
+
+import (
+	"context"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// rollback deletes the objects uploaded by the run after an upload failed.
+// Objects uploaded to versioned buckets are deleted by version, restoring
+// the previous version of replaced objects.
+func (o *Options) rollback(ctx context.Context, client S3API, files []File) error {
+	var objects []types.ObjectIdentifier
+	for _, f := range files {
+		object := types.ObjectIdentifier{Key: aws.String(f.Key)}
+		if f.VersionID != "" {
+			object.VersionId = aws.String(f.VersionID)
+		}
+		objects = append(objects, object)
+
+		if o.state != nil {
+			o.state.forget(o.stateKey(f.Key))
+		}
+	}
+	if len(objects) == 0 {
+		return nil
+	}
+
+	log.WithFields(log.Fields{
+		"bucket": o.Bucket,
+		"count":  len(objects),
+	}).Warn("Rolling back the uploaded files")
+
+	if err := o.removeObjects(ctx, client, objects); err != nil {
+		log.WithFields(log.Fields{
+			"bucket": o.Bucket,
+			"error":  err,
+		}).Error("Could not roll back the uploaded files")
+		return err
+	}
+	return nil
+}
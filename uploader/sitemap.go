@@ -0,0 +1,70 @@
+package uploader
+
+import (
+	"context"
+	"encoding/xml"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+)
+
+// sitemapName is the name of the generated sitemap, uploaded to the target.
+const sitemapName = "sitemap.xml"
+
+// sitemap is the XML sitemap of the site, as defined by sitemaps.org.
+type sitemap struct {
+	XMLName xml.Name     `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 urlset"`
+	URLs    []sitemapURL `xml:"url"`
+}
+
+type sitemapURL struct {
+	Loc string `xml:"loc"`
+}
+
+// sitemapURLs returns the sorted URLs of the uploaded pages on the site. The
+// index pages are linked by their folder, which the website serves them for.
+func (o *Options) sitemapURLs(uploaded map[string]bool) []string {
+	root := o.targetPrefix()
+	base := strings.TrimSuffix(o.BaseURL, "/") + "/"
+
+	var urls []string
+	for key := range uploaded {
+		rel := strings.TrimPrefix(key, "/")
+		if !isPage(rel) || !strings.HasPrefix(rel, root) {
+			continue
+		}
+		rel = strings.TrimPrefix(rel, root)
+		if rel == "index.html" || strings.HasSuffix(rel, "/index.html") {
+			rel = strings.TrimSuffix(rel, "index.html")
+		}
+		urls = append(urls, base+escapeKey(rel))
+	}
+	sort.Strings(urls)
+	return urls
+}
+
+// uploadSitemap uploads the sitemap of the uploaded pages to the target.
+func (o *Options) uploadSitemap(ctx context.Context, uploader *manager.Uploader, uploads *manifest, uploaded map[string]bool) error {
+	target := strings.TrimPrefix(strings.TrimSuffix(o.Target, "/")+"/"+sitemapName, "/")
+	if o.LeadingSlash {
+		target = "/" + target
+	}
+	if uploaded[target] {
+		return nil
+	}
+
+	urls := o.sitemapURLs(uploaded)
+	uploaded[target] = true
+	return o.putGenerated(ctx, uploader, uploads, "sitemap", target, "application/xml", func() ([]byte, error) {
+		s := sitemap{}
+		for _, u := range urls {
+			s.URLs = append(s.URLs, sitemapURL{Loc: u})
+		}
+		out, err := xml.MarshalIndent(s, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		return append([]byte(xml.Header), append(out, '\n')...), nil
+	})
+}
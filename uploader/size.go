@@ -0,0 +1,27 @@
+package uploader
+
+import (
+	"strconv"
+)
+
+// sizeUnits lists the units of human readable sizes, largest first.
+var sizeUnits = []struct {
+	suffix string
+	size   int64
+}{
+	{"TB", 1 << 40},
+	{"GB", 1 << 30},
+	{"MB", 1 << 20},
+	{"KB", 1 << 10},
+}
+
+// formatSize is a helper function that formats a number of bytes as a human
+// readable size such as 1.5GB.
+func formatSize(size int64) string {
+	for _, unit := range sizeUnits {
+		if size >= unit.size {
+			return strconv.FormatFloat(float64(size)/float64(unit.size), 'f', 1, 64) + unit.suffix
+		}
+	}
+	return strconv.FormatInt(size, 10) + "B"
+}
@@ -0,0 +1,54 @@
+package uploader
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/smithy-go/middleware"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+// skewThreshold is the clock skew from which requests rejected as forbidden
+// are considered rejected because of the skew. S3 rejects requests signed
+// more than 15 minutes off.
+const skewThreshold = 5 * time.Minute
+
+// correctClockSkew retries the requests rejected because of the clock skew of
+// the runner once. The SDK measures the skew from the Date header of every
+// response and signs the following requests with the corrected time, but
+// doesn't retry when retries are disabled, nor HEAD requests since their
+// responses have no error code.
+func correctClockSkew(stack *middleware.Stack) error {
+	retry := middleware.FinalizeMiddlewareFunc("ClockSkewCorrection", func(ctx context.Context, in middleware.FinalizeInput, next middleware.FinalizeHandler) (middleware.FinalizeOutput, middleware.Metadata, error) {
+		out, metadata, err := next.HandleFinalize(ctx, in)
+		if err == nil {
+			return out, metadata, err
+		}
+
+		var respErr *awshttp.ResponseError
+		if !errors.As(err, &respErr) || respErr.HTTPStatusCode() != http.StatusForbidden {
+			return out, metadata, err
+		}
+		skew, ok := awsmiddleware.GetAttemptSkew(metadata)
+		if !ok || (skew < skewThreshold && skew > -skewThreshold) {
+			return out, metadata, err
+		}
+
+		req, ok := in.Request.(*smithyhttp.Request)
+		if !ok || req.RewindStream() != nil {
+			return out, metadata, err
+		}
+
+		log.WithFields(log.Fields{
+			"operation": middleware.GetOperationName(ctx),
+			"skew":      skew.Round(time.Second).String(),
+		}).Warn("Retrying with the clock corrected by the skew from the S3 time")
+		return next.HandleFinalize(ctx, in)
+	})
+	return stack.Finalize.Insert(retry, "Retry", middleware.Before)
+}
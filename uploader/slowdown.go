@@ -0,0 +1,147 @@
+package uploader
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/smithy-go"
+	"github.com/aws/smithy-go/middleware"
+)
+
+const (
+	// slowDownAttempts is the minimum number of attempts of requests
+	// throttled with SlowDown responses.
+	slowDownAttempts = 10
+
+	// slowDownCooldown is the minimum interval between two reductions of
+	// the upload concurrency, so a burst of SlowDown responses to the
+	// uploads in flight only reduces it once.
+	slowDownCooldown = time.Second
+)
+
+// isSlowDown is a helper function that reports whether S3 throttled the
+// request, either with a SlowDown error or a 503 response without body.
+func isSlowDown(err error) bool {
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) && apiErr.ErrorCode() == "SlowDown" {
+		return true
+	}
+	var respErr *awshttp.ResponseError
+	return errors.As(err, &respErr) && respErr.HTTPStatusCode() == http.StatusServiceUnavailable
+}
+
+// slowDownRetryer retries SlowDown responses at least slowDownAttempts times
+// with a longer backoff, while other errors are retried up to the configured
+// number of attempts.
+type slowDownRetryer struct {
+	aws.RetryerV2
+	attempts int
+	backoff  *backoff
+}
+
+func (r *slowDownRetryer) MaxAttempts() int {
+	if r.attempts > slowDownAttempts {
+		return r.attempts
+	}
+	return slowDownAttempts
+}
+
+func (r *slowDownRetryer) RetryDelay(attempt int, err error) (time.Duration, error) {
+	if isSlowDown(err) {
+		return r.backoff.BackoffDelay(attempt, err)
+	}
+	if attempt >= r.attempts {
+		return 0, &retry.MaxAttemptsError{Attempt: attempt, Err: err}
+	}
+	return r.RetryerV2.RetryDelay(attempt, err)
+}
+
+// concurrency limits the number of files uploaded concurrently. The limit is
+// halved on SlowDown responses and grows back by one after each limit files
+// uploaded without SlowDown responses.
+type concurrency struct {
+	mu        sync.Mutex
+	cond      *sync.Cond
+	max       int
+	limit     int
+	active    int
+	succeeded int
+	reduced   time.Time
+}
+
+// newConcurrency creates the limiter of at most max concurrent uploads.
+func newConcurrency(max int) *concurrency {
+	if max < 1 {
+		max = 1
+	}
+	c := &concurrency{max: max, limit: max}
+	c.cond = sync.NewCond(&c.mu)
+	return c
+}
+
+// acquire waits until another file can be uploaded.
+func (c *concurrency) acquire() {
+	c.mu.Lock()
+	for c.active >= c.limit {
+		c.cond.Wait()
+	}
+	c.active++
+	c.mu.Unlock()
+}
+
+// release ends the upload of a file, growing the limit back after enough
+// successful uploads.
+func (c *concurrency) release(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.active--
+	if err == nil && c.limit < c.max {
+		if c.succeeded++; c.succeeded >= c.limit {
+			c.limit++
+			c.succeeded = 0
+		}
+	}
+	c.cond.Broadcast()
+}
+
+// slowDown halves the limit, at most once per cooldown.
+func (c *concurrency) slowDown() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.limit == 1 || time.Since(c.reduced) < slowDownCooldown {
+		return
+	}
+	c.limit /= 2
+	c.succeeded = 0
+	c.reduced = time.Now()
+
+	log.WithFields(log.Fields{
+		"parallel": c.limit,
+	}).Warn("Reducing the upload concurrency after SlowDown responses")
+}
+
+// clientOption returns a client option reducing the limit on every SlowDown
+// response, including the responses retried by the SDK.
+func (c *concurrency) clientOption() func(*s3.Options) {
+	detect := middleware.DeserializeMiddlewareFunc("SlowDownDetection", func(ctx context.Context, in middleware.DeserializeInput, next middleware.DeserializeHandler) (middleware.DeserializeOutput, middleware.Metadata, error) {
+		out, metadata, err := next.HandleDeserialize(ctx, in)
+		if err != nil && isSlowDown(err) {
+			c.slowDown()
+		}
+		return out, metadata, err
+	})
+
+	return func(opts *s3.Options) {
+		opts.APIOptions = append(opts.APIOptions, func(stack *middleware.Stack) error {
+			return stack.Deserialize.Add(detect, middleware.Before)
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package uploader
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// uploadState records the content hashes of the uploaded files keyed by
+// object, so the following runs skip the files uploaded before.
+type uploadState struct {
+	mu       sync.Mutex
+	previous map[string]string
+	current  map[string]string
+}
+
+// newState returns the state of the previous run encoded as a JSON object,
+// or an empty state when b is empty.
+func newState(b []byte) (*uploadState, error) {
+	s := &uploadState{
+		previous: map[string]string{},
+		current:  map[string]string{},
+	}
+	if len(b) == 0 {
+		return s, nil
+	}
+	if err := json.Unmarshal(b, &s.previous); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// loadState is a helper function that reads the state of the previous run
+// from the file. A missing file returns an empty state.
+func loadState(path string) (*uploadState, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	s, err := newState(b)
+	if err != nil {
+		return nil, fmt.Errorf("invalid state file %s: %s", path, err)
+	}
+	return s, nil
+}
+
+// stateKey returns the key of the object in the state. The state file also
+// records the bucket, the remote manifest is stored in the bucket.
+func (o *Options) stateKey(target string) string {
+	if o.RemoteManifest {
+		return target
+	}
+	return o.Bucket + "/" + target
+}
+
+// unchanged reports whether the object was uploaded with the same content by
+// the previous run, keeping the object in the state.
+func (s *uploadState) unchanged(key, hash string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.previous[key] != hash {
+		return false
+	}
+	s.current[key] = hash
+	return true
+}
+
+// keep keeps the object uploaded by the previous run in the state, e.g. for
+// files skipped as not changed by the commits.
+func (s *uploadState) keep(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if hash, ok := s.previous[key]; ok {
+		s.current[key] = hash
+	}
+}
+
+// forget drops the object from the state, e.g. for objects rolled back, so
+// the following runs upload the file again.
+func (s *uploadState) forget(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.current, key)
+}
+
+// uploaded records the upload of the object.
+func (s *uploadState) uploaded(key, hash string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.current[key] = hash
+}
+
+// encode returns the objects of the run encoded as a JSON object, dropping
+// the objects of files that no longer exist.
+func (s *uploadState) encode() ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out, err := json.MarshalIndent(s.current, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(out, '\n'), nil
+}
+
+// save writes the objects of the run to the file.
+func (s *uploadState) save(path string) error {
+	out, err := s.encode()
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(path, out, 0644); err != nil {
+		log.WithFields(log.Fields{
+			"file":  path,
+			"error": err,
+		}).Error("Could not write the state file")
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+package uploader
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// maxDeleteKeys is the maximum number of keys accepted by a single
+// DeleteObjects request.
+const maxDeleteKeys = 1000
+
+// sync deletes all objects under the target prefixes of the mappings that are
+// not part of the uploaded key set, mirroring the behavior of
+// `aws s3 sync --delete`. Nothing is deleted when more objects than the
+// maximum would be deleted.
+func (o *Options) sync(ctx context.Context, client S3API, mappings []*Options, uploaded map[string]bool) error {
+	var stale []string
+	seen := map[string]bool{}
+	for _, m := range mappings {
+		keys, err := m.stale(ctx, client, uploaded)
+		if err != nil {
+			return err
+		}
+		for _, key := range keys {
+			if !seen[key] {
+				seen[key] = true
+				stale = append(stale, key)
+			}
+		}
+	}
+
+	return o.delete(ctx, client, stale)
+}
+
+// delete deletes the objects with the given keys, unless more objects than the
+// maximum would be deleted. Executing a dry-run only reports the objects.
+func (o *Options) delete(ctx context.Context, client S3API, keys []string) error {
+	if err := o.checkMaxDelete(len(keys)); err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		log.WithFields(log.Fields{
+			"bucket": o.Bucket,
+			"target": key,
+		}).Info("Deleting file")
+	}
+
+	// when executing a dry-run we only report the objects.
+	if o.DryRun {
+		return nil
+	}
+
+	return o.remove(ctx, client, keys)
+}
+
+// checkMaxDelete fails when deleting the number of files exceeds the maximum
+// number of files to delete.
+func (o *Options) checkMaxDelete(count int) error {
+	if o.MaxDelete > 0 && count > o.MaxDelete {
+		log.WithFields(log.Fields{
+			"bucket":     o.Bucket,
+			"count":      count,
+			"max-delete": o.MaxDelete,
+		}).Error("Too many files to delete")
+		return fmt.Errorf("refusing to delete %d files, more than the maximum of %d", count, o.MaxDelete)
+	}
+	return nil
+}
+
+// stale returns the keys of the objects under the target prefix that are not
+// part of the uploaded key set.
+func (o *Options) stale(ctx context.Context, client S3API, uploaded map[string]bool) ([]string, error) {
+	prefix := o.Target
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix = prefix + "/"
+	}
+	if o.LeadingSlash {
+		prefix = "/" + prefix
+	}
+
+	var stale []string
+	paginator := s3.NewListObjectsV2Paginator(client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(o.Bucket),
+		Prefix: aws.String(prefix),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"bucket": o.Bucket,
+				"prefix": prefix,
+				"error":  err,
+			}).Error("Could not list remote objects")
+			return nil, err
+		}
+		for _, object := range page.Contents {
+			key := aws.ToString(object.Key)
+			if !uploaded[key] {
+				stale = append(stale, key)
+			}
+		}
+	}
+	return stale, nil
+}
+
+// remove deletes the objects with the given keys in batches.
+func (o *Options) remove(ctx context.Context, client S3API, stale []string) error {
+	objects := make([]types.ObjectIdentifier, len(stale))
+	for i, key := range stale {
+		objects[i] = types.ObjectIdentifier{Key: aws.String(key)}
+	}
+	return o.removeObjects(ctx, client, objects)
+}
+
+// removeObjects deletes the objects, or the given versions of the objects,
+// in batches.
+func (o *Options) removeObjects(ctx context.Context, client S3API, objects []types.ObjectIdentifier) error {
+	for len(objects) > 0 {
+		n := len(objects)
+		if n > maxDeleteKeys {
+			n = maxDeleteKeys
+		}
+		batch := objects[:n]
+		objects = objects[n:]
+
+		out, err := client.DeleteObjects(ctx, &s3.DeleteObjectsInput{
+			Bucket: aws.String(o.Bucket),
+			Delete: &types.Delete{
+				Objects: batch,
+				Quiet:   aws.Bool(true),
+			},
+		})
+		if err != nil {
+			log.WithFields(log.Fields{
+				"bucket": o.Bucket,
+				"error":  err,
+			}).Error("Could not delete files")
+			return err
+		}
+		if len(out.Errors) != 0 {
+			err := out.Errors[0]
+			log.WithFields(log.Fields{
+				"bucket": o.Bucket,
+				"target": aws.ToString(err.Key),
+				"error":  aws.ToString(err.Message),
+			}).Error("Could not delete file")
+			return fmt.Errorf("could not delete %s: %s", aws.ToString(err.Key), aws.ToString(err.Message))
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,22 @@
+package uploader
+
+import (
+	"net/url"
+	"sort"
+)
+
+// encodeTags is a helper function that returns the tags encoded as url query
+// parameters, as expected by the x-amz-tagging header.
+func encodeTags(tags map[string]string) string {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	values := url.Values{}
+	for _, k := range keys {
+		values.Set(k, tags[k])
+	}
+	return values.Encode()
+}
@@ -0,0 +1,94 @@
+package uploader
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// throttleChunk is the maximum number of bytes read at once from a throttled
+// body, keeping the transfer rate smooth.
+const throttleChunk = 32 << 10
+
+// limiter limits the rate of the bytes shared by all readers.
+type limiter struct {
+	rate int64
+
+	mu   sync.Mutex
+	next time.Time
+}
+
+// newLimiter returns a limiter allowing the number of bytes per second.
+func newLimiter(rate int64) *limiter {
+	return &limiter{rate: rate}
+}
+
+// wait blocks until the bytes are allowed by the rate, or the context is done.
+func (l *limiter) wait(ctx context.Context, n int) error {
+	l.mu.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	at := l.next
+	l.next = l.next.Add(time.Duration(n) * time.Second / time.Duration(l.rate))
+	l.mu.Unlock()
+
+	d := time.Until(at)
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// throttledBody is a request body read at the rate of the limiter.
+type throttledBody struct {
+	io.ReadCloser
+	ctx     context.Context
+	limiter *limiter
+}
+
+func (b *throttledBody) Read(p []byte) (int, error) {
+	if len(p) > throttleChunk {
+		p = p[:throttleChunk]
+	}
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		if werr := b.limiter.wait(b.ctx, n); werr != nil {
+			return n, werr
+		}
+	}
+	return n, err
+}
+
+// throttledClient sends the request bodies at the rate of the limiter, which
+// is shared by all concurrent requests.
+type throttledClient struct {
+	base    aws.HTTPClient
+	limiter *limiter
+}
+
+func (c *throttledClient) Do(req *http.Request) (*http.Response, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return c.base.Do(req)
+	}
+
+	throttled := *req
+	throttled.Body = &throttledBody{
+		ReadCloser: req.Body,
+		ctx:        req.Context(),
+		limiter:    c.limiter,
+	}
+	return c.base.Do(&throttled)
+}
@@ -0,0 +1,117 @@
+package uploader
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+
+	log "github.com/Sirupsen/logrus"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+)
+
+// newHTTPClient creates the HTTP client used by the SDK, trusting the
+// private CAs in addition to the system roots.
+func (o *Options) newHTTPClient() (*awshttp.BuildableClient, error) {
+	client := awshttp.NewBuildableClient()
+
+	pool, err := o.rootCAs()
+	if err != nil {
+		return nil, err
+	}
+	if pool != nil {
+		client = client.WithTransportOptions(func(tr *http.Transport) {
+			tr.TLSClientConfig.RootCAs = pool
+		})
+	}
+
+	// accept any certificate, e.g. the self-signed certificates of lab
+	// MinIO instances.
+	if o.InsecureSkipVerify {
+		log.WithFields(log.Fields{
+			"endpoint": o.Endpoint,
+		}).Warn("Skipping the TLS certificate verification, the connections are insecure")
+
+		client = client.WithTransportOptions(func(tr *http.Transport) {
+			tr.TLSClientConfig.InsecureSkipVerify = true
+		})
+	}
+
+	// the proxy of the environment (HTTPS_PROXY, HTTP_PROXY and NO_PROXY)
+	// is used unless a proxy is configured.
+	if o.Proxy != "" {
+		// the URL isn't logged, it may contain the proxy credentials.
+		proxy, err := url.Parse(o.Proxy)
+		if err != nil || proxy.Host == "" {
+			return nil, errors.New("invalid proxy url")
+		}
+		client = client.WithTransportOptions(func(tr *http.Transport) {
+			tr.Proxy = http.ProxyURL(proxy)
+		})
+	}
+
+	if o.ConnectTimeout > 0 {
+		client = client.WithDialerOptions(func(d *net.Dialer) {
+			d.Timeout = o.ConnectTimeout
+		})
+	}
+	client = client.WithTransportOptions(func(tr *http.Transport) {
+		if o.ResponseHeaderTimeout > 0 {
+			tr.ResponseHeaderTimeout = o.ResponseHeaderTimeout
+		}
+		// keep a connection of all concurrent requests, the default pool
+		// closes the connections of the additional requests after each
+		// request.
+		tr.MaxIdleConnsPerHost = o.maxIdleConnsPerHost()
+		if tr.MaxIdleConns < tr.MaxIdleConnsPerHost {
+			tr.MaxIdleConns = tr.MaxIdleConnsPerHost
+		}
+	})
+	return client, nil
+}
+
+// maxIdleConnsPerHost returns the number of idle connections kept per host,
+// defaulting to the number of concurrent requests.
+func (o *Options) maxIdleConnsPerHost() int {
+	if o.MaxIdleConnsPerHost > 0 {
+		return o.MaxIdleConnsPerHost
+	}
+	parts := o.PartConcurrency
+	if parts == 0 {
+		parts = manager.DefaultUploadConcurrency
+	}
+	if n := o.Parallel * parts; n > awshttp.DefaultHTTPTransportMaxIdleConnsPerHost {
+		return n
+	}
+	return awshttp.DefaultHTTPTransportMaxIdleConnsPerHost
+}
+
+// rootCAs returns the system roots with the certificates of the private CAs
+// added, or nil when no private CA is configured.
+func (o *Options) rootCAs() (*x509.CertPool, error) {
+	if o.CACert == "" && o.CACertPath == "" {
+		return nil, nil
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if o.CACert != "" && !pool.AppendCertsFromPEM([]byte(o.CACert)) {
+		return nil, errors.New("no certificates found in the ca_cert")
+	}
+	if o.CACertPath != "" {
+		pem, err := os.ReadFile(o.CACertPath)
+		if err != nil {
+			return nil, err
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", o.CACertPath)
+		}
+	}
+	return pool, nil
+}
@@ -0,0 +1,1702 @@
+// Package uploader uploads the local files matching Glob patterns to an S3
+// bucket, or downloads the matching objects. It implements the Drone S3
+// plugin and is reusable by other tools.
+package uploader
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/andybalholm/brotli"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/mattn/go-zglob"
+)
+
+// Options defines the upload settings.
+type Options struct {
+	Endpoint string
+	Key      string
+	Secret   string
+	Bucket   string
+
+	// Endpoints tried in order when the endpoint can't be reached, e.g.
+	// the replicas of a MinIO cluster.
+	FailoverEndpoints []string
+
+	// Additional buckets the files are uploaded to after the bucket.
+	Destinations []Destination
+
+	// Bucket the objects are copied from by Copy, defaults to the bucket.
+	SourceBucket string
+
+	// Number of days the objects restored by Restore stay available,
+	// defaults to 1, and the retrieval tier, which should be one of the
+	// following:
+	//     Standard
+	//     Bulk
+	//     Expedited
+	RestoreDays int
+	RestoreTier string
+
+	// Custom endpoints of the services other than S3, keyed by service:
+	//     sts
+	//     cloudfront
+	Endpoints map[string]string
+
+	// Send unsigned requests, e.g. to public buckets.
+	Anonymous bool
+
+	// S3 client used instead of the client created from the settings, e.g.
+	// the in-memory Memory client.
+	Client S3API
+
+	// Pay for the requests to a requester pays bucket.
+	RequesterPays bool
+
+	// Create the bucket when missing, with an optional canned ACL.
+	CreateBucket bool
+	BucketACL    string
+
+	// Skip the check of the bucket access before uploading, e.g. for
+	// credentials that can't list the bucket. The probe additionally
+	// uploads and deletes an empty object to check the write access.
+	SkipPreflight  bool
+	PreflightProbe bool
+
+	// Fail when the bucket has no default encryption, preventing the files
+	// from being stored in plaintext.
+	RequireEncryption bool
+
+	// IAM role to assume before uploading, with an optional external ID
+	// and session name.
+	AssumeRole      string
+	ExternalID      string
+	RoleSessionName string
+
+	// Role assumed with the web identity token file, used by IAM roles for
+	// service accounts on EKS.
+	RoleARN              string
+	WebIdentityTokenFile string
+
+	// us-east-1
+	// us-west-1
+	// us-west-2
+	// eu-west-1
+	// ap-southeast-1
+	// ap-southeast-2
+	// ap-northeast-1
+	// sa-east-1
+	Region string
+
+	// Indicates the files ACL, which should be one
+	// of the following:
+	//     private
+	//     public-read
+	//     public-read-write
+	//     authenticated-read
+	//     bucket-owner-read
+	//     bucket-owner-full-control
+	//     none
+	//
+	// No ACL is sent with none, as required by buckets with ACLs disabled.
+	Access string
+
+	// Grants of the files, replacing the ACL, as lists of grantees like
+	// id="canonical-user-id" or
+	// uri="http://acs.amazonaws.com/groups/global/AllUsers"
+	GrantRead        string
+	GrantReadACP     string
+	GrantWriteACP    string
+	GrantFullControl string
+
+	// Copies the files from the specified directory.
+	// Regexp matching will apply to match multiple
+	// files. The files matching any of the patterns
+	// are uploaded.
+	//
+	// Examples:
+	//    /path/to/file
+	//    /path/to/*.txt
+	//    /path/to/*/*.txt
+	//    /path/to/**
+	Source []string
+	Target string
+
+	// Additional source to target mappings uploaded by the same step,
+	// replacing the source. Unset mapping settings default to the step
+	// settings.
+	Mappings []Mapping
+
+	// Strip the prefix from the local file paths before joining them
+	// with the target.
+	StripPrefix string
+	// Upload the files to keys starting with a slash, otherwise the keys
+	// have no leading slash.
+	LeadingSlash bool
+	// Rules renaming the object keys, applied in order.
+	Rewrites []Rewrite
+
+	// Recursive uploads
+	Recursive bool
+
+	// Exclude files matching this pattern.
+	Exclude []string
+	// Exclude files ignored by the .gitignore files.
+	UseGitignore bool
+	// Exclude the hidden files and folders below the folder of the source
+	// patterns, unless the patterns match them by name, e.g. .well-known.
+	ExcludeHidden bool
+	// Minimum and maximum size of the uploaded files, files outside of the
+	// limits are skipped. The maximum size is unlimited when zero.
+	MinSize int64
+	MaxSize int64
+	// Maximum number and total size of the files matched by all sources,
+	// failing before uploading any file, unlimited when zero.
+	MaxFiles     int
+	MaxTotalSize int64
+	// Handling of symlinks, which should be one of the following:
+	//     follow
+	//     skip
+	//     error
+	Symlinks string
+
+	// Fail when no files match the source, otherwise only warn.
+	FailOnEmptySource bool
+
+	// Timeout of the whole run and of the upload of a single file.
+	Timeout     time.Duration
+	FileTimeout time.Duration
+
+	// Timeouts of connecting and of waiting for the response headers once
+	// the request is sent, and the number of idle connections kept for
+	// reuse. The connections kept default to the concurrent requests.
+	ConnectTimeout        time.Duration
+	ResponseHeaderTimeout time.Duration
+	MaxIdleConnsPerHost   int
+
+	// Maximum number of times a failed request is retried, with a delay
+	// growing exponentially from the base delay up to the maximum delay.
+	MaxRetries     int
+	RetryBaseDelay time.Duration
+	RetryMaxDelay  time.Duration
+	// Retry mode, which should be one of the following:
+	//     standard
+	//     adaptive
+	RetryMode string
+	// Number of times the upload of a file is attempted again from the
+	// start when interrupted, in addition to the retries of the requests.
+	RetriesPerFile int
+
+	// Use the dual-stack (IPv6) and FIPS endpoints.
+	UseDualstack bool
+	UseFIPS      bool
+
+	// PEM encoded certificates of private CAs trusted in addition to the
+	// system roots, e.g. for MinIO or Ceph endpoints, either inline or read
+	// from the path.
+	CACert     string
+	CACertPath string
+
+	// Skip the verification of the TLS certificates, e.g. for self-signed
+	// certificates. Insecure, only meant for test endpoints.
+	InsecureSkipVerify bool
+
+	// URL of the proxy used for all requests, e.g. http://proxy:3128,
+	// instead of the proxy of the environment.
+	Proxy string
+
+	// Use path style instead of domain style.
+	//
+	// Should be true for minio and false for AWS.
+	PathStyle bool
+	// Dry run without uploading/
+	DryRun bool
+	// Compress objects and upload with Content-Encoding: gzip
+	Compress bool
+	// Compression encoding, which should be one of the following:
+	//     gzip
+	//     br
+	Encoding string
+	// Files larger than this size are compressed into a temp file instead
+	// of streaming the compressed content, disabled when zero.
+	CompressDiskThreshold int64
+	// Content types keyed by file Glob pattern, overriding the content
+	// type detected from the file extension, e.g. application/wasm for
+	// *.wasm
+	ContentType map[string]string
+	// Do not append the UTF-8 charset to text content types.
+	OmitCharset bool
+	// Cache-Control header values keyed by file Glob pattern.
+	CacheControl map[string]string
+	// Expires header of all files, unset when zero.
+	Expires time.Time
+	// Object Lock retention applied to the uploaded objects, unset when
+	// the retain until date is zero. The mode should be one of the
+	// following:
+	//     GOVERNANCE
+	//     COMPLIANCE
+	ObjectLockMode        string
+	ObjectLockRetainUntil time.Time
+	// Storage class keyed by file Glob pattern, e.g. STANDARD_IA for *.log
+	StorageClass map[string]string
+	// Object metadata keyed by file Glob pattern. The metadata of all
+	// matching patterns is merged.
+	Metadata map[string]map[string]string
+	// Store the modification time and the permissions of the files as the
+	// mtime and mode metadata, restored by the downloads.
+	PreserveAttributes bool
+	// Object tags applied to all files.
+	Tags map[string]string
+	// Number of days after which the files expire, applied as the ttl tag
+	// like ttl=30d, with the matching bucket lifecycle rule optionally
+	// added before uploading.
+	ExpireAfter         int
+	ExpireLifecycleRule bool
+	// Website redirect locations keyed by object key relative to the
+	// target, uploaded as empty objects, e.g. old.html: /new.html
+	Redirects map[string]string
+	// Apply the defaults of static websites: no-cache for pages, immutable
+	// caching for hashed assets, pages uploaded last and redirects of
+	// folder keys to the trailing slash.
+	Website bool
+	// Upload a sitemap.xml of the uploaded pages to the target in website
+	// mode, linking the pages under the base URL of the site.
+	Sitemap bool
+	BaseURL string
+	// File Glob patterns of the assets renamed with a hash of their content,
+	// e.g. assets/app.js to assets/app.3f9ab2c1.js, and uploaded with
+	// immutable caching. The renames are added to the report.
+	Fingerprint []string
+	// Hash algorithm of the Subresource Integrity hashes of the uploaded
+	// scripts and stylesheets added to the report, e.g. sha384.
+	Integrity string
+	// Content-Encoding of files compressed by the build keyed by file Glob
+	// pattern, e.g. *.gz: gzip. Matching files are not compressed again.
+	ContentEncoding map[string]string
+	// Skip files with the same content as the remote object, based on the
+	// object ETag.
+	OnlyChanged bool
+	// Policy for existing objects: always overwrite them, never overwrite
+	// them failing the upload, or only if the file is newer or different.
+	Overwrite string
+	// Fail instead of replacing existing objects using conditional writes,
+	// also when another run writes the object concurrently.
+	Immutable bool
+	// Upload the files to a staging area of the bucket first, copying them
+	// to the target once all files are uploaded and verified.
+	Atomic bool
+	// Delete the objects uploaded by the run when an upload fails, restoring
+	// the previous versions in versioned buckets.
+	Rollback bool
+	// Upload all files when uploads fail, failing once all files are
+	// uploaded.
+	ContinueOnError bool
+	// File Glob patterns of the entry points uploaded after all other files,
+	// including the website pages, e.g. index.html.
+	UploadLast []string
+	// Delete remote objects under the target that were not uploaded.
+	Sync bool
+	// Maximum number of objects deleted when syncing, unlimited when zero.
+	MaxDelete int
+	// Number of builds and days of builds kept in the parent folder of the
+	// target, unlimited when zero.
+	RetainBuilds int
+	RetainDays   int
+	// CloudFront distribution invalidated after uploading, by default for
+	// all paths under the target.
+	CloudFrontDistribution string
+	InvalidationPaths      []string
+	// Static website configuration and CORS rules applied to the bucket
+	// after uploading, replacing the existing configuration.
+	WebsiteConfig *WebsiteConfig
+	CORS          []CORSRule
+	// Presign the download URLs of the uploaded files, valid for the
+	// expiry duration, defaulting to 24 hours. The URLs are only listed in
+	// the manifest written to the manifest path.
+	Presign        bool
+	PresignExpires time.Duration
+	Manifest       string
+	// Add the public URLs of the uploaded files to the report.
+	PublicURLs bool
+	// Upload an index.html page listing the objects of each directory of
+	// the uploaded files, unless the directory has an uploaded index page.
+	GenerateIndex bool
+	// Upload an empty folder marker object like docs/ for each directory of
+	// the uploaded files.
+	FolderMarkers bool
+	// Number of files to upload concurrently.
+	Parallel int
+	// Order the files are started in, which should be one of the following:
+	//     fifo
+	//     largest-first
+	Schedule string
+	// Interval of the progress reports, disabled when zero.
+	ProgressInterval time.Duration
+	// Maximum bytes per second uploaded by all uploads together, unlimited
+	// when zero.
+	BandwidthLimit int64
+
+	// Size in bytes of the parts used for multipart uploads. Files larger
+	// than a single part are uploaded in multiple parts.
+	PartSize int64
+	// Number of parts of a single file to upload concurrently.
+	PartConcurrency int
+
+	// Server-side encryption algorithm, which should be one of the
+	// following:
+	//     AES256
+	//     aws:kms
+	Encryption string
+	// KMS key used when encrypting with aws:kms. The bucket default key
+	// is used when empty.
+	KMSKeyID string
+
+	// Additional checksum algorithm, which should be one of the following:
+	//     CRC32
+	//     CRC32C
+	//     SHA1
+	//     SHA256
+	ChecksumAlgorithm string
+	// Send the Content-MD5 header with every upload request.
+	ContentMD5 bool
+	// Check the size, ETag and checksum of each object after uploading.
+	Verify bool
+
+	// Customer-provided encryption key (SSE-C), either as the raw 32 byte
+	// key or base64 encoded.
+	SSECustomerKey string
+	// Customer-provided key algorithm, defaults to AES256.
+	SSECustomerAlgorithm string
+
+	// Only upload the files changed between the commits according to git
+	// diff, keeping the other files when syncing. All files are uploaded
+	// when the commits can't be diffed.
+	ChangedOnly  bool
+	CommitBefore string
+	CommitAfter  string
+
+	// File recording the content hashes of the uploaded files, so the
+	// following runs only upload the files changed since.
+	StateFile string
+	// Record the content hashes of the uploaded files in a manifest object
+	// of the target instead, shared by the runners uploading to the bucket.
+	RemoteManifest bool
+
+	// files changed between the commits, nil to upload all files.
+	changed map[string]bool
+	// state of the uploads read from the state file or remote manifest.
+	state *uploadState
+	// staging area of atomic uploads.
+	staging *staging
+}
+
+// Upload uploads the files matching the source patterns to the bucket. The
+// report lists the uploaded files, or the files planned to be uploaded when
+// executing a dry-run, including the files uploaded before an error occurred.
+func Upload(ctx context.Context, opts Options) (Report, error) {
+	if err := opts.validate(); err != nil {
+		return Report{}, err
+	}
+
+	// the keys are relative to the bucket root.
+	opts.Target = strings.TrimPrefix(opts.Target, "/")
+	opts.Mappings = append([]Mapping(nil), opts.Mappings...)
+	for i := range opts.Mappings {
+		opts.Mappings[i].Target = strings.TrimPrefix(opts.Mappings[i].Target, "/")
+	}
+
+	// bound the whole run by the global timeout
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+
+	if opts.ChangedOnly {
+		opts.changed = opts.changedFiles(ctx)
+	}
+
+	if opts.StateFile != "" {
+		state, err := loadState(opts.StateFile)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"file":  opts.StateFile,
+				"error": err,
+			}).Error("Could not read the state file")
+			return Report{}, err
+		}
+		opts.state = state
+	}
+
+	report, err := opts.exec(ctx)
+	report.Bucket = opts.Bucket
+	if len(opts.Destinations) != 0 {
+		err = opts.fanOut(ctx, &report, err)
+	}
+
+	// the state also records the files uploaded before an error occurred.
+	if opts.StateFile != "" && !opts.DryRun {
+		if serr := opts.state.save(opts.StateFile); serr != nil && err == nil {
+			err = serr
+		}
+	}
+	return report, err
+}
+
+// Download downloads the objects matching the source patterns from the bucket
+// into the target folder.
+func Download(ctx context.Context, opts Options) error {
+	if err := opts.validate(); err != nil {
+		return err
+	}
+
+	// bound the whole run by the global timeout
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+
+	_, client, err := opts.connect(ctx)
+	if err != nil {
+		return err
+	}
+	return opts.download(ctx, client)
+}
+
+// Secrets returns the credentials and keys of the options in all forms they
+// are sent in, e.g. to redact them from the log output.
+func (o *Options) Secrets() []string {
+	key := customerKey(o.SSECustomerKey)
+	secrets := []string{
+		o.Key,
+		o.Secret,
+		o.SSECustomerKey,
+		key,
+		base64.StdEncoding.EncodeToString([]byte(key)),
+	}
+	for _, d := range o.Destinations {
+		secrets = append(secrets, d.Key, d.Secret)
+	}
+	return secrets
+}
+
+// validate checks the options, applying the defaults of unset options.
+func (o *Options) validate() error {
+	switch o.Encoding {
+	case "":
+		o.Encoding = "gzip"
+	case "gzip", "br":
+	default:
+		return fmt.Errorf("unsupported encoding %q", o.Encoding)
+	}
+
+	switch o.Symlinks {
+	case "":
+		o.Symlinks = "follow"
+	case "follow", "skip", "error":
+	default:
+		return fmt.Errorf("unsupported symlinks policy %q", o.Symlinks)
+	}
+
+	switch o.Schedule {
+	case "":
+		o.Schedule = "fifo"
+	case "fifo", "largest-first":
+	default:
+		return fmt.Errorf("unsupported schedule %q", o.Schedule)
+	}
+
+	switch o.RetryMode {
+	case "":
+		o.RetryMode = "standard"
+	case "standard", "adaptive":
+	default:
+		return fmt.Errorf("unsupported retry mode %q", o.RetryMode)
+	}
+
+	if o.ChecksumAlgorithm != "" {
+		if _, err := newChecksum(o.ChecksumAlgorithm); err != nil {
+			return err
+		}
+		o.ChecksumAlgorithm = strings.ToUpper(o.ChecksumAlgorithm)
+	}
+
+	for service := range o.Endpoints {
+		switch service {
+		case "sts", "cloudfront":
+		default:
+			return fmt.Errorf("unsupported endpoint service %q", service)
+		}
+	}
+
+	for key, location := range o.Redirects {
+		if !validRedirect(location) {
+			return fmt.Errorf("invalid redirect location %q of %s, must be a path or an http(s) URL", location, key)
+		}
+	}
+
+	for i, m := range o.Mappings {
+		if len(m.Source) == 0 {
+			return fmt.Errorf("invalid mappings: mapping %d has no source", i+1)
+		}
+	}
+
+	rewrites, err := compileRewrites(o.Rewrites)
+	if err != nil {
+		return err
+	}
+	o.Rewrites = rewrites
+
+	// a kms key implies kms encryption
+	if o.KMSKeyID != "" && o.Encryption == "" {
+		o.Encryption = "aws:kms"
+	}
+	if o.SSECustomerKey != "" && o.SSECustomerAlgorithm == "" {
+		o.SSECustomerAlgorithm = "AES256"
+	}
+	return o.checkSettings()
+}
+
+// connect creates the AWS config and the S3 client.
+func (o *Options) connect(ctx context.Context) (aws.Config, S3API, error) {
+	cfg, err := o.newConfig(ctx)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err,
+		}).Error("Could not load the AWS config")
+		return cfg, nil, err
+	}
+	if o.Client != nil {
+		return cfg, o.Client, nil
+	}
+	client := o.newClient(cfg)
+
+	// requests sent to the wrong region fail, so use the region of the
+	// bucket on AWS.
+	if o.Endpoint == "" && o.detectRegion(ctx, client) {
+		client = o.newClient(cfg)
+	}
+	return cfg, client, nil
+}
+
+// exec uploads the files of all mappings, then removes the stale objects and
+// builds and invalidates the CloudFront cache when configured.
+func (o *Options) exec(ctx context.Context) (Report, error) {
+	cfg, client, err := o.connect(ctx)
+	if err != nil {
+		return Report{}, err
+	}
+
+	progress := newProgress()
+
+	// reduce the number of concurrent uploads while S3 is throttling.
+	limiter := newConcurrency(o.Parallel)
+
+	uploader := manager.NewUploader(client, func(u *manager.Uploader) {
+		u.ClientOptions = append(u.ClientOptions, progress.clientOption(), limiter.clientOption())
+		// only compute checksums of multipart uploads when configured.
+		u.RequestChecksumCalculation = aws.RequestChecksumCalculationWhenRequired
+		if o.PartSize != 0 {
+			u.PartSize = o.PartSize
+		}
+		if o.PartConcurrency != 0 {
+			u.Concurrency = o.PartConcurrency
+		}
+		// S3 requires a checksum of the uploads to Object Lock buckets.
+		if o.ContentMD5 || o.objectLock() && o.ChecksumAlgorithm == "" {
+			u.ClientOptions = append(u.ClientOptions, withContentMD5())
+		}
+	})
+
+	// find the bucket
+	log.WithFields(log.Fields{
+		"region":   o.Region,
+		"endpoint": o.Endpoint,
+		"bucket":   o.Bucket,
+	}).Info("Attempting to upload")
+
+	if o.CreateBucket {
+		if err := o.createBucket(ctx, client); err != nil {
+			return Report{}, err
+		}
+	}
+
+	// fail on misconfigured credentials before compressing any file.
+	if !o.SkipPreflight && !o.DryRun {
+		if err := o.preflight(ctx, client); err != nil {
+			return Report{}, err
+		}
+	}
+
+	if o.RequireEncryption {
+		if err := o.requireEncryption(ctx, client); err != nil {
+			return Report{}, err
+		}
+	}
+
+	if o.ExpireLifecycleRule && o.ExpireAfter > 0 {
+		if err := o.lifecycleRule(ctx, client); err != nil {
+			return Report{}, err
+		}
+	}
+
+	// track the uploaded keys so stale objects can be removed when syncing.
+	uploaded := map[string]bool{}
+
+	// each bucket is diffed against its own remote manifest, which is kept
+	// when syncing.
+	if o.RemoteManifest {
+		if o.state, err = o.loadRemoteManifest(ctx, client); err != nil {
+			return Report{}, err
+		}
+		uploaded[o.remoteManifestKey()] = true
+	}
+
+	// collect the uploaded files for the report, or the planned uploads
+	// when executing a dry-run.
+	uploads := &manifest{}
+
+	// periodically report the progress of long uploads.
+	if o.ProgressInterval > 0 && !o.DryRun {
+		done := make(chan struct{})
+		defer close(done)
+		go progress.report(o.ProgressInterval, done)
+	}
+
+	// the pages generated from the uploaded files are uploaded once the
+	// staged files are copied to the target.
+	if o.Atomic && !o.DryRun {
+		o.staging = newStaging()
+	}
+
+	mappings := o.mappings()
+
+	// fail before uploading any file when the source matches too much.
+	if o.MaxFiles > 0 || o.MaxTotalSize > 0 {
+		if err := o.checkLimits(mappings); err != nil {
+			return Report{}, err
+		}
+	}
+
+	for _, m := range mappings {
+		merr := m.put(ctx, client, uploader, uploads, progress, limiter, uploaded)
+		if merr != nil && err == nil {
+			err = merr
+		}
+		if err != nil && !o.ContinueOnError {
+			break
+		}
+	}
+	if o.staging != nil {
+		err = o.swap(ctx, client, err)
+	}
+	if err == nil && o.FolderMarkers {
+		err = o.folderMarkers(ctx, uploader, uploads, uploaded)
+	}
+	if err == nil && o.GenerateIndex {
+		err = o.generateIndexes(ctx, client, uploader, uploads, uploaded)
+	}
+	if err == nil {
+		err = o.redirect(ctx, uploader, uploads, uploaded, o.redirects(uploaded))
+	}
+	if err == nil && o.Website && o.Sitemap {
+		err = o.uploadSitemap(ctx, uploader, uploads, uploaded)
+	}
+	if !o.DryRun {
+		progress.summary()
+	}
+
+	report := newReport(uploads, progress)
+	if len(report.Failures) > 1 && o.ContinueOnError {
+		err = fmt.Errorf("%d files failed to upload, first error: %s", len(report.Failures), report.Failures[0].Error)
+	}
+
+	if err != nil && o.Rollback && !o.DryRun {
+		if rerr := o.rollback(ctx, client, report.Files); rerr != nil {
+			err = fmt.Errorf("%s, could not roll back: %s", err, rerr)
+		}
+	}
+
+	// the manifest also records the files uploaded before an error occurred.
+	if o.RemoteManifest && !o.DryRun {
+		if merr := o.saveRemoteManifest(ctx, client); merr != nil && err == nil {
+			err = merr
+		}
+	}
+	if err != nil {
+		return report, err
+	}
+
+	if o.PublicURLs {
+		if err := o.publicURLs(ctx, &report); err != nil {
+			return report, err
+		}
+	}
+
+	if o.Presign && !o.DryRun {
+		if err := o.presign(ctx, client, &report); err != nil {
+			return report, err
+		}
+	}
+
+	if o.Sync {
+		if err := o.sync(ctx, client, mappings, uploaded); err != nil {
+			return report, err
+		}
+	}
+
+	if o.RetainBuilds > 0 || o.RetainDays > 0 {
+		for _, m := range mappings {
+			if err := m.retain(ctx, client); err != nil {
+				return report, err
+			}
+		}
+	}
+
+	if err := o.configureBucket(ctx, client); err != nil {
+		return report, err
+	}
+
+	if o.CloudFrontDistribution != "" {
+		return report, o.invalidate(ctx, cfg, mappings)
+	}
+
+	return report, nil
+}
+
+// put uploads all files matching the source to the target, recording the
+// uploaded keys.
+func (o *Options) put(ctx context.Context, client S3API, uploader *manager.Uploader, uploads *manifest, progress *progress, limiter *concurrency, uploaded map[string]bool) error {
+	files, err := o.sourceFiles()
+	if err != nil {
+		return err
+	}
+	progress.add(files)
+
+	if len(files) == 0 {
+		fields := log.Fields{
+			"source":  strings.Join(o.Source, ","),
+			"exclude": strings.Join(o.Exclude, ","),
+		}
+		if o.FailOnEmptySource {
+			log.WithFields(fields).Error("No files matched the source")
+			return fmt.Errorf("no files matched the source %s", strings.Join(o.Source, ", "))
+		}
+		log.WithFields(fields).Warn("No files matched the source")
+	}
+
+	// website pages and entry points are uploaded after the assets, so they
+	// never reference assets that are not uploaded yet.
+	for _, batch := range o.batches(files) {
+		// start the largest files first, so a large file started last
+		// doesn't hold up the end of the batch.
+		if o.Schedule == "largest-first" {
+			largestFirst(batch)
+		}
+		if berr := o.putFiles(ctx, client, uploader, uploads, progress, limiter, uploaded, batch); berr != nil {
+			if !o.ContinueOnError {
+				return berr
+			}
+			if err == nil {
+				err = berr
+			}
+		}
+	}
+	return err
+}
+
+// sourceFiles returns the files matching the source to upload.
+func (o *Options) sourceFiles() ([]string, error) {
+	matches, err := matches(o.Source, o.Exclude, !o.ExcludeHidden)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err,
+		}).Error("Could not match files")
+		return nil, err
+	}
+
+	// skip files listed in the ignore files of the source roots
+	if matches, err = ignored(matches, o.Source); err != nil {
+		log.WithFields(log.Fields{
+			"error": err,
+		}).Error("Could not read the ignore file")
+		return nil, err
+	}
+
+	// skip files ignored by git
+	if o.UseGitignore {
+		if matches, err = gitignored(matches); err != nil {
+			log.WithFields(log.Fields{
+				"error": err,
+			}).Error("Could not read the .gitignore files")
+			return nil, err
+		}
+	}
+
+	return o.files(matches)
+}
+
+// putFiles uploads the files concurrently, recording the uploaded keys.
+func (o *Options) putFiles(ctx context.Context, client S3API, uploader *manager.Uploader, uploads *manifest, progress *progress, limiter *concurrency, uploaded map[string]bool, files []string) error {
+	// fan the uploads out across a bounded pool of workers, of which the
+	// limiter lets fewer upload while S3 is throttling. once a worker
+	// reports an error no further files are queued, unless continuing on
+	// errors.
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		errs   []error
+		queued = make(chan upload)
+	)
+	for i := 0; i < limiter.max; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for u := range queued {
+				limiter.acquire()
+				err := o.upload(ctx, client, uploader, uploads, progress, u.name, u.target)
+				limiter.release(err)
+				if err != nil {
+					atomic.AddInt64(&progress.failed, 1)
+					uploads.failed(u.name, u.target, err)
+					mu.Lock()
+					errs = append(errs, err)
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+
+	for _, match := range files {
+		mu.Lock()
+		failed := len(errs) != 0
+		mu.Unlock()
+		if failed && !o.ContinueOnError || ctx.Err() != nil {
+			break
+		}
+
+		target := rewriteKey(resolveKey(o.Target, match, o.StripPrefix), o.Rewrites)
+		if o.fingerprinted(relPath(match, o.StripPrefix)) {
+			hash, err := fingerprint(match)
+			if err != nil {
+				log.WithFields(log.Fields{
+					"name":  match,
+					"error": err,
+				}).Error("Could not fingerprint file")
+				atomic.AddInt64(&progress.failed, 1)
+				uploads.failed(match, target, err)
+				mu.Lock()
+				errs = append(errs, err)
+				mu.Unlock()
+				continue
+			}
+			renamed := fingerprintKey(target, hash)
+			uploads.fingerprint(strings.TrimPrefix(target, o.targetPrefix()), strings.TrimPrefix(renamed, o.targetPrefix()))
+			target = renamed
+		}
+		if o.LeadingSlash {
+			target = "/" + target
+		}
+
+		uploaded[target] = true
+
+		// the unchanged files are kept when syncing, the remote objects
+		// are up to date.
+		if !o.changedFile(match) {
+			if o.state != nil {
+				o.state.keep(o.stateKey(target))
+			}
+			progress.skip(match)
+			continue
+		}
+		queued <- upload{name: match, target: target}
+	}
+	close(queued)
+	wg.Wait()
+
+	if len(errs) == 0 && ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	switch len(errs) {
+	case 0:
+		return nil
+	case 1:
+		return errs[0]
+	default:
+		return fmt.Errorf("%d files failed to upload, first error: %s", len(errs), errs[0])
+	}
+}
+
+// largestFirst is a helper function that sorts the files by size, largest
+// first, keeping the order of files of the same size.
+func largestFirst(files []string) {
+	sizes := make(map[string]int64, len(files))
+	for _, name := range files {
+		if stat, err := os.Stat(name); err == nil {
+			sizes[name] = stat.Size()
+		}
+	}
+	sort.SliceStable(files, func(i, j int) bool {
+		return sizes[files[i]] > sizes[files[j]]
+	})
+}
+
+// files is a helper function that returns the matched files to upload,
+// skipping directories and applying the symlink policy. Files reached through
+// several links are only uploaded once, preferring the file itself.
+func (o *Options) files(matches []string) ([]string, error) {
+	var files, links []string
+	for _, match := range matches {
+		stat, err := os.Lstat(match)
+		if err != nil {
+			continue // should never happen
+		}
+
+		link := stat.Mode()&os.ModeSymlink != 0
+		if link {
+			switch o.Symlinks {
+			case "skip":
+				log.WithFields(log.Fields{
+					"name": match,
+				}).Info("Skipping symlink")
+				continue
+			case "error":
+				log.WithFields(log.Fields{
+					"name": match,
+				}).Error("Found symlink")
+				return nil, fmt.Errorf("%s is a symlink", match)
+			}
+
+			if stat, err = os.Stat(match); err != nil {
+				log.WithFields(log.Fields{
+					"name":  match,
+					"error": err,
+				}).Warn("Skipping broken symlink")
+				continue
+			}
+		}
+
+		// skip directories
+		if stat.IsDir() {
+			continue
+		}
+
+		// skip files outside of the size limits, e.g. empty files or
+		// core dumps of the build.
+		if stat.Size() < o.MinSize {
+			log.WithFields(log.Fields{
+				"name": match,
+				"size": stat.Size(),
+			}).Info("Skipping file smaller than the minimum size")
+			continue
+		}
+		if o.MaxSize > 0 && stat.Size() > o.MaxSize {
+			log.WithFields(log.Fields{
+				"name":     match,
+				"size":     formatSize(stat.Size()),
+				"max-size": formatSize(o.MaxSize),
+			}).Warn("Skipping file larger than the maximum size")
+			continue
+		}
+
+		if link {
+			links = append(links, match)
+		} else {
+			files = append(files, match)
+		}
+	}
+
+	var deduped []string
+	seen := map[string]bool{}
+	for _, match := range append(files, links...) {
+		real, err := filepath.EvalSymlinks(match)
+		if err != nil {
+			real = match
+		}
+		if seen[real] {
+			log.WithFields(log.Fields{
+				"name": match,
+				"file": real,
+			}).Info("Skipping file already matched through another link")
+			continue
+		}
+		seen[real] = true
+		deduped = append(deduped, match)
+	}
+	return deduped, nil
+}
+
+// upload is a single file scheduled for upload.
+type upload struct {
+	name   string
+	target string
+}
+
+// upload uploads a single file to the target key.
+func (o *Options) upload(ctx context.Context, client S3API, uploader *manager.Uploader, uploads *manifest, progress *progress, match, target string) error {
+	// path of the file used for pattern matching.
+	rel := relPath(match, o.StripPrefix)
+
+	// amazon S3 has pretty crappy default content-type headers so this pluign
+	// attempts to provide a proper content-type.
+	content := contentType(match)
+
+	// files compressed by the build are uploaded as-is with the content-type
+	// of the uncompressed file.
+	encoding := lookup(o.ContentEncoding, rel)
+	precompressed := encoding != ""
+	if precompressed {
+		content = contentType(trimCompressedExt(match))
+	} else if o.Compress {
+		encoding = o.Encoding
+	}
+
+	// the system mime table is often incomplete, so the configured content
+	// types take precedence. Files compressed by the build also match the
+	// patterns of the uncompressed file.
+	typ := lookup(o.ContentType, rel)
+	if typ == "" && precompressed {
+		typ = lookup(o.ContentType, trimCompressedExt(rel))
+	}
+	if typ != "" {
+		content = typ
+	}
+	if !o.OmitCharset {
+		content = withCharset(content)
+	}
+
+	// encoding of the content compressed while uploading.
+	var compress string
+	if o.Compress && !precompressed {
+		compress = encoding
+	}
+
+	// skip files uploaded with the same content by the previous run.
+	var hash string
+	if o.state != nil {
+		var err error
+		if hash, err = fileHash(match); err != nil {
+			log.WithFields(log.Fields{
+				"name":  match,
+				"error": err,
+			}).Error("Could not hash file")
+			return err
+		}
+		if o.state.unchanged(o.stateKey(target), hash) {
+			log.WithFields(log.Fields{
+				"name":   match,
+				"bucket": o.Bucket,
+				"target": target,
+				"result": "skipped",
+			}).Info("Skipping unchanged file")
+			progress.skip(match)
+			return nil
+		}
+	}
+
+	// keep the existing object according to the overwrite policy.
+	skip, err := o.skipExisting(ctx, client, match, target, compress)
+	if err != nil {
+		return err
+	}
+	if skip {
+		log.WithFields(log.Fields{
+			"name":   match,
+			"bucket": o.Bucket,
+			"target": target,
+			"result": "skipped",
+		}).Info("Skipping unchanged file")
+		progress.skip(match)
+		return nil
+	}
+
+	//prepare upload
+	// atomic uploads are uploaded to the staging area first.
+	key := target
+	if o.staging != nil {
+		key = o.staging.key(target)
+	}
+
+	input := &s3.PutObjectInput{
+		Bucket:      aws.String(o.Bucket),
+		Key:         aws.String(key),
+		ContentType: aws.String(content),
+	}
+	o.applyACL(input)
+
+	//optionally encrypt
+	if o.Encryption != "" {
+		input.ServerSideEncryption = types.ServerSideEncryption(o.Encryption)
+	}
+	if o.KMSKeyID != "" {
+		input.SSEKMSKeyId = aws.String(o.KMSKeyID)
+	}
+
+	cacheControl := lookup(o.CacheControl, rel)
+	if cacheControl == "" && o.fingerprinted(rel) {
+		cacheControl = immutableCacheControl
+	}
+	if cacheControl == "" && o.Website {
+		cacheControl = websiteCacheControl(rel)
+	}
+	if cacheControl != "" {
+		input.CacheControl = aws.String(cacheControl)
+	}
+
+	if !o.Expires.IsZero() {
+		input.Expires = aws.Time(o.Expires)
+	}
+
+	// the staged objects are retained once copied to the target, so the
+	// staging area can be removed.
+	if o.objectLock() && o.staging == nil {
+		input.ObjectLockMode = types.ObjectLockMode(o.ObjectLockMode)
+		input.ObjectLockRetainUntilDate = aws.Time(o.ObjectLockRetainUntil)
+	}
+
+	// only write the object when the key doesn't exist, so concurrent
+	// uploads can't replace an object uploaded by another run.
+	if o.Immutable {
+		input.IfNoneMatch = aws.String("*")
+	}
+
+	if storageClass := lookup(o.StorageClass, rel); storageClass != "" {
+		input.StorageClass = types.StorageClass(storageClass)
+	}
+
+	metadata := lookupAll(o.Metadata, rel)
+	if o.PreserveAttributes {
+		attributes, err := fileAttributes(match)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"name":  match,
+				"error": err,
+			}).Error("Could not stat file")
+			return err
+		}
+		for k, v := range attributes {
+			metadata[k] = v
+		}
+	}
+	if len(metadata) != 0 {
+		input.Metadata = metadata
+	}
+
+	if tags := o.tags(); len(tags) != 0 {
+		input.Tagging = aws.String(encodeTags(tags))
+	}
+
+	if o.SSECustomerKey != "" {
+		input.SSECustomerKey, input.SSECustomerKeyMD5 = sseCustomerKey(o.SSECustomerKey)
+		input.SSECustomerAlgorithm = aws.String(o.SSECustomerAlgorithm)
+	}
+
+	// the SDK computes the additional checksum, also of the parts of
+	// multipart uploads.
+	if o.ChecksumAlgorithm != "" {
+		input.ChecksumAlgorithm = types.ChecksumAlgorithm(o.ChecksumAlgorithm)
+	}
+
+	//set encoding
+	if encoding != "" {
+		input.ContentEncoding = aws.String(encoding)
+	}
+
+	// log file for debug purposes.
+	log.WithFields(log.Fields{
+		"name":             match,
+		"bucket":           o.Bucket,
+		"target":           target,
+		"content-type":     content,
+		"content-encoding": encoding,
+	}).Info("Uploading file")
+
+	if o.Integrity != "" && hasIntegrity(rel) {
+		var decode string
+		if precompressed {
+			decode = encoding
+		}
+		hash, err := integrity(o.Integrity, match, decode)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"name":  match,
+				"error": err,
+			}).Error("Could not compute the integrity hash")
+			return err
+		}
+		if hash != "" {
+			uploads.integrity(target, hash)
+		}
+	}
+
+	// when executing a dry-run we exit because we don't actually want to
+	// upload the file to S3, only report the planned upload.
+	if o.DryRun {
+		return uploads.add(match, input, nil)
+	}
+
+	//upload, opening the file again for each attempt.
+	start := time.Now()
+	var (
+		output *manager.UploadOutput
+		size   int64
+	)
+	for attempt := 1; ; attempt++ {
+		output, size, err = o.putFile(ctx, uploader, progress, input, match, compress)
+		if err == nil || attempt > o.RetriesPerFile || !retryFile(ctx, err) {
+			break
+		}
+		log.WithFields(log.Fields{
+			"name":    match,
+			"bucket":  o.Bucket,
+			"target":  target,
+			"attempt": attempt,
+			"error":   err,
+		}).Warn("Retrying file upload")
+	}
+
+	if err != nil && o.Immutable && isPreconditionFailed(err) {
+		log.WithFields(log.Fields{
+			"name":   match,
+			"bucket": o.Bucket,
+			"target": target,
+			"result": "failed",
+			"error":  err,
+		}).Error("Object already exists")
+
+		return fmt.Errorf("object %s already exists in bucket %s, immutable objects are never overwritten", target, o.Bucket)
+	}
+	if err != nil {
+		log.WithFields(log.Fields{
+			"name":     match,
+			"bucket":   o.Bucket,
+			"target":   target,
+			"size":     size,
+			"duration": time.Since(start).String(),
+			"result":   "failed",
+			"error":    err,
+		}).Error("Could not upload file")
+
+		return err
+	}
+
+	fields := log.Fields{
+		"name":     match,
+		"bucket":   o.Bucket,
+		"target":   target,
+		"size":     size,
+		"duration": time.Since(start).String(),
+		"result":   "uploaded",
+	}
+	// versioned buckets return the version of the uploaded object.
+	if output.VersionID != nil {
+		fields["version"] = aws.ToString(output.VersionID)
+	}
+	log.WithFields(fields).Info("Uploaded file")
+	atomic.AddInt64(&progress.uploaded, 1)
+
+	// check the uploaded object against the local file, always verifying
+	// the staged objects before copying them to the target.
+	if o.Verify || o.staging != nil {
+		if err := o.verify(ctx, client, match, key, compress); err != nil {
+			log.WithFields(log.Fields{
+				"name":   match,
+				"bucket": o.Bucket,
+				"target": target,
+				"error":  err,
+			}).Error("Could not verify file")
+			return err
+		}
+	}
+
+	// the staged files are recorded in the state once copied to the target,
+	// the report lists the target instead of the staged object.
+	if o.staging != nil {
+		o.staging.add(key, target, rel, hash)
+		input.Key = aws.String(target)
+		output.VersionID = nil
+	} else if o.state != nil {
+		o.state.uploaded(o.stateKey(target), hash)
+	}
+	return uploads.add(match, input, output)
+}
+
+// putFile uploads the file with the input, compressing the content when
+// compress is set, returning the size of the file.
+func (o *Options) putFile(ctx context.Context, uploader *manager.Uploader, progress *progress, input *s3.PutObjectInput, match, compress string) (*manager.UploadOutput, int64, error) {
+	f, err := os.Open(match)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err,
+			"file":  match,
+		}).Error("Problem opening file")
+		return nil, 0, err
+	}
+	defer f.Close()
+
+	stat, err := f.Stat()
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err,
+			"file":  match,
+		}).Error("Problem reading file")
+		return nil, 0, err
+	}
+
+	// bound the upload of a single file by the file timeout
+	if o.FileTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, o.FileTimeout)
+		defer cancel()
+	}
+
+	// bytes of the upload attempt, counted as the bytes of the source file.
+	var a *attempt
+
+	//optionally compress
+	if compress != "" && o.CompressDiskThreshold > 0 && stat.Size() > o.CompressDiskThreshold {
+		//compress large files into a temp file first. the uploader reads
+		//the parts from the file instead of buffering them in memory.
+		tmp, err := compressFile(f, compress)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"error": err,
+				"file":  match,
+			}).Error("Problem compressing file")
+			return nil, 0, err
+		}
+		defer os.Remove(tmp.Name())
+		defer tmp.Close()
+		input.Body = tmp
+
+		// the requests carry the compressed bytes, which are counted as
+		// the share of the source bytes.
+		ratio := 1.0
+		if compressed, err := tmp.Stat(); err == nil && compressed.Size() > 0 {
+			ratio = float64(stat.Size()) / float64(compressed.Size())
+		}
+		ctx, a = progress.attempt(ctx, ratio)
+	} else if compress != "" {
+		//stream the compressed file to the uploader. the uploader only
+		//buffers a single part at a time, so memory use remains bounded.
+		pr, pw := io.Pipe()
+		defer pr.Close()
+		// the compressed bytes of the requests are unknown up front, so
+		// the bytes read from the file are counted instead.
+		ctx, a = progress.attempt(ctx, 0)
+		go func() {
+			cw := compressor(compress, pw)
+			_, err := io.Copy(cw, io.TeeReader(f, a))
+			if err == nil {
+				err = cw.Close()
+			}
+			if err != nil && err != io.ErrClosedPipe {
+				log.WithFields(log.Fields{
+					"error": err,
+					"file":  match,
+				}).Error("Problem compressing file")
+			}
+			pw.CloseWithError(err)
+		}()
+		input.Body = pr
+	} else {
+		input.Body = f
+		ctx, a = progress.attempt(ctx, 1)
+	}
+
+	output, err := uploader.Upload(ctx, input)
+	a.done(stat.Size(), err)
+	return output, stat.Size(), err
+}
+
+// matches is a helper function that returns a list of all files matching the
+// included Glob patterns, while excluding all files that matche the exclusion
+// Glob pattners. Files matching several included patterns are listed once.
+func matches(include, exclude []string, hidden bool) ([]string, error) {
+	var matches []string
+	seen := map[string]bool{}
+	for _, pattern := range include {
+		globbed, err := glob(pattern)
+		if err != nil {
+			return nil, err
+		}
+		for _, match := range globbed {
+			if !hidden && isHidden(pattern, match) {
+				continue
+			}
+			if !seen[match] {
+				seen[match] = true
+				matches = append(matches, match)
+			}
+		}
+	}
+	if len(exclude) == 0 {
+		return matches, nil
+	}
+
+	// find all files that are excluded and load into a map. we can verify
+	// each file in the list is not a member of the exclusion list.
+	excludem := map[string]bool{}
+	for _, pattern := range exclude {
+		excludes, err := glob(pattern)
+		if err != nil {
+			return nil, err
+		}
+		for _, match := range excludes {
+			excludem[match] = true
+		}
+	}
+
+	var included []string
+	for _, include := range matches {
+		_, ok := excludem[include]
+		if ok {
+			continue
+		}
+		included = append(included, include)
+	}
+	return included, nil
+}
+
+// glob is a helper function that returns the files matching the Glob pattern,
+// or none if the pattern refers to a missing folder.
+func glob(pattern string) ([]string, error) {
+	matches, err := zglob.Glob(pattern)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return matches, err
+}
+
+// lookup is a helper function that returns the value of the longest Glob
+// pattern matching the file path, or an empty string if none match.
+func lookup(values map[string]string, path string) string {
+	var pattern, value string
+	for p, v := range values {
+		if len(p) > len(pattern) && matchPattern(p, path) {
+			pattern, value = p, v
+		}
+	}
+	return value
+}
+
+// lookupAll is a helper function that merges the values of all Glob patterns
+// matching the file path. Values of longer patterns take precedence.
+func lookupAll(values map[string]map[string]string, path string) map[string]string {
+	var patterns []string
+	for pattern := range values {
+		if matchPattern(pattern, path) {
+			patterns = append(patterns, pattern)
+		}
+	}
+	sort.Sort(byLength(patterns))
+
+	merged := map[string]string{}
+	for _, pattern := range patterns {
+		for k, v := range values[pattern] {
+			merged[k] = v
+		}
+	}
+	return merged
+}
+
+// byLength sorts patterns from the shortest to the longest.
+type byLength []string
+
+func (s byLength) Len() int      { return len(s) }
+func (s byLength) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s byLength) Less(i, j int) bool {
+	if len(s[i]) != len(s[j]) {
+		return len(s[i]) < len(s[j])
+	}
+	return s[i] < s[j]
+}
+
+// matchPattern is a helper function that reports whether the file path
+// matches the Glob pattern. Patterns without a slash, such as *.html, are
+// matched against the file name in any folder.
+func matchPattern(pattern, name string) bool {
+	name = filepath.ToSlash(name)
+	if !strings.Contains(pattern, "/") {
+		name = path.Base(name)
+	}
+	ok, _ := zglob.Match(pattern, name)
+	return ok
+}
+
+// slashPath is a helper function that returns the path with forward slashes.
+// Backslashes are converted on all platforms, as paths of Windows builds,
+// e.g. in the strip prefix or the target, also show up on Linux runners.
+func slashPath(name string) string {
+	return strings.Replace(filepath.ToSlash(name), `\`, "/", -1)
+}
+
+// relPath is a helper function that returns the slash separated file path
+// after removing the strip prefix.
+func relPath(name, stripPrefix string) string {
+	return strings.TrimPrefix(slashPath(name), slashPath(stripPrefix))
+}
+
+// resolveKey is a helper function that returns the object key for the file,
+// joining the target with the file path after removing the strip prefix. Keys
+// always use forward slashes, also for paths of Windows files.
+func resolveKey(target, name, stripPrefix string) string {
+	return strings.TrimPrefix(path.Join(slashPath(target), relPath(name, stripPrefix)), "/")
+}
+
+// targetPrefix returns the prefix of the keys under the target, without the
+// leading slash.
+func (o *Options) targetPrefix() string {
+	if prefix := strings.Trim(slashPath(o.Target), "/"); prefix != "" {
+		return prefix + "/"
+	}
+	return ""
+}
+
+// compressor is a helper function that returns a writer compressing to w
+// using the content encoding.
+func compressor(encoding string, w io.Writer) io.WriteCloser {
+	if encoding == "br" {
+		return brotli.NewWriter(w)
+	}
+	return gzip.NewWriter(w)
+}
+
+// compressFile is a helper function that compresses the file into a temp
+// file, returned at the start of the compressed content. The caller removes
+// the temp file.
+func compressFile(f *os.File, encoding string) (*os.File, error) {
+	tmp, err := ioutil.TempFile("", "drone-s3-")
+	if err != nil {
+		return nil, err
+	}
+
+	cw := compressor(encoding, tmp)
+	_, err = io.Copy(cw, f)
+	if err == nil {
+		err = cw.Close()
+	}
+	if err == nil {
+		_, err = tmp.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return nil, err
+	}
+	return tmp, nil
+}
+
+// compressedExts lists the file extensions of compressed files.
+var compressedExts = map[string]bool{
+	".gz":  true,
+	".br":  true,
+	".zst": true,
+}
+
+// trimCompressedExt is a helper function that returns the path of the
+// uncompressed file, e.g. app.js for app.js.gz.
+func trimCompressedExt(path string) string {
+	ext := filepath.Ext(path)
+	if compressedExts[ext] {
+		return strings.TrimSuffix(path, ext)
+	}
+	return path
+}
+
+// applyACL sets the grants of the upload, or the canned ACL when no grants
+// are configured. S3 rejects uploads with both.
+func (o *Options) applyACL(input *s3.PutObjectInput) {
+	if o.grants() {
+		input.GrantRead = optional(o.GrantRead)
+		input.GrantReadACP = optional(o.GrantReadACP)
+		input.GrantWriteACP = optional(o.GrantWriteACP)
+		input.GrantFullControl = optional(o.GrantFullControl)
+		return
+	}
+	if o.Access != "none" {
+		input.ACL = types.ObjectCannedACL(o.Access)
+	}
+}
+
+// objectLock reports whether the Object Lock retention is configured.
+func (o *Options) objectLock() bool {
+	return !o.ObjectLockRetainUntil.IsZero()
+}
+
+// grants reports whether any grant is configured.
+func (o *Options) grants() bool {
+	return o.GrantRead != "" || o.GrantReadACP != "" || o.GrantWriteACP != "" || o.GrantFullControl != ""
+}
+
+// optional is a helper function that returns a pointer to the string, or nil
+// when empty.
+func optional(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return aws.String(s)
+}
+
+// contentType is a helper function that returns the content type for the file
+// based on extension. If the file extension is unknown application/octet-stream
+// is returned.
+func contentType(path string) string {
+	ext := filepath.Ext(path)
+	typ := mime.TypeByExtension(ext)
+	if typ == "" {
+		typ = "application/octet-stream"
+	}
+	return typ
+}
+
+// withCharset is a helper function that appends the UTF-8 charset to text
+// content types without charset.
+func withCharset(content string) string {
+	typ, params, err := mime.ParseMediaType(content)
+	if err != nil || params["charset"] != "" {
+		return content
+	}
+	switch {
+	case strings.HasPrefix(typ, "text/"),
+		typ == "application/javascript",
+		typ == "application/json",
+		typ == "application/xml",
+		typ == "image/svg+xml":
+		return content + "; charset=utf-8"
+	}
+	return content
+}
+
+// customerKey is a helper function that returns the raw SSE-C key, decoding
+// keys that are provided base64 encoded. The raw key is encoded and hashed
+// for the requests by sseCustomerKey.
+func customerKey(key string) string {
+	if len(key) == 32 {
+		return key
+	}
+	raw, err := base64.StdEncoding.DecodeString(key)
+	if err != nil || len(raw) != 32 {
+		return key
+	}
+	return string(raw)
+}
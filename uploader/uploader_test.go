@@ -0,0 +1,334 @@
+package uploader
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// chdir creates the files in a temp folder and changes into the folder for
+// the duration of the test, as the source patterns match relative paths.
+func chdir(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+	return dir
+}
+
+// uploadedKeys is a helper function that returns the sorted keys of the
+// uploaded files.
+func uploadedKeys(report Report) []string {
+	var keys []string
+	for _, f := range report.Files {
+		keys = append(keys, f.Key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func TestUploadMatch(t *testing.T) {
+	chdir(t, map[string]string{
+		"a.txt":          "a",
+		"b.log":          "b",
+		"sub/c.txt":      "c",
+		"sub/skip/d.txt": "d",
+	})
+
+	tests := []struct {
+		name    string
+		source  []string
+		exclude []string
+		want    []string
+	}{
+		{"single", []string{"a.txt"}, nil, []string{"a.txt"}},
+		{"glob", []string{"*.txt"}, nil, []string{"a.txt"}},
+		{"recursive", []string{"**/*.txt"}, nil, []string{"a.txt", "sub/c.txt", "sub/skip/d.txt"}},
+		{"exclude", []string{"**/*.txt"}, []string{"sub/skip/**"}, []string{"a.txt", "sub/c.txt"}},
+		{"multiple", []string{"*.log", "sub/*.txt"}, nil, []string{"b.log", "sub/c.txt"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewMemory("bucket")
+			report, err := Upload(context.Background(), Options{
+				Client:        m,
+				SkipPreflight: true,
+				Bucket:        "bucket",
+				Source:        tt.source,
+				Exclude:       tt.exclude,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := uploadedKeys(report); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got keys %v, want %v", got, tt.want)
+			}
+			if got := m.Keys("bucket"); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got objects %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUploadKeys(t *testing.T) {
+	chdir(t, map[string]string{
+		"dist/index.html":    "index",
+		"dist/assets/app.js": "app",
+	})
+
+	tests := []struct {
+		name        string
+		target      string
+		stripPrefix string
+		want        []string
+	}{
+		{"root", "", "", []string{"dist/assets/app.js", "dist/index.html"}},
+		{"target", "site", "", []string{"site/dist/assets/app.js", "site/dist/index.html"}},
+		{"leading slash", "/site/", "", []string{"site/dist/assets/app.js", "site/dist/index.html"}},
+		{"strip prefix", "", "dist/", []string{"assets/app.js", "index.html"}},
+		{"strip prefix and target", "site/v1", "dist/", []string{"site/v1/assets/app.js", "site/v1/index.html"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewMemory("bucket")
+			report, err := Upload(context.Background(), Options{
+				Client:        m,
+				SkipPreflight: true,
+				Bucket:        "bucket",
+				Source:        []string{"dist/**"},
+				Target:        tt.target,
+				StripPrefix:   tt.stripPrefix,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := uploadedKeys(report); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got keys %v, want %v", got, tt.want)
+			}
+			for _, key := range tt.want {
+				if m.Object("bucket", key) == nil {
+					t.Errorf("object %s not uploaded", key)
+				}
+			}
+		})
+	}
+}
+
+func TestUploadError(t *testing.T) {
+	chdir(t, map[string]string{
+		"a.txt": "a",
+		"b.txt": "b",
+	})
+
+	failed := errors.New("put failed")
+	m := NewMemory("bucket")
+	m.Fail = func(operation, key string) error {
+		if operation == "PutObject" && key == "b.txt" {
+			return failed
+		}
+		return nil
+	}
+
+	report, err := Upload(context.Background(), Options{
+		Client:        m,
+		SkipPreflight: true,
+		Bucket:        "bucket",
+		Source:        []string{"*.txt"},
+	})
+	if err == nil || !strings.Contains(err.Error(), failed.Error()) {
+		t.Fatalf("got error %v, want %v", err, failed)
+	}
+	if m.Object("bucket", "b.txt") != nil {
+		t.Error("failed object b.txt stored")
+	}
+	for _, f := range report.Files {
+		if f.Key == "b.txt" {
+			t.Error("failed file b.txt reported as uploaded")
+		}
+	}
+}
+
+func TestUploadMissingBucket(t *testing.T) {
+	chdir(t, map[string]string{
+		"a.txt": "a",
+	})
+
+	_, err := Upload(context.Background(), Options{
+		Client:        NewMemory(),
+		SkipPreflight: true,
+		Bucket:        "bucket",
+		Source:        []string{"a.txt"},
+	})
+	if err == nil {
+		t.Fatal("got no error uploading to a missing bucket")
+	}
+}
+
+func TestDownload(t *testing.T) {
+	dir := chdir(t, nil)
+
+	m := NewMemory("bucket")
+	for _, key := range []string{"dist/index.html", "dist/assets/app.js", "dist/../../escaped", "other.txt"} {
+		_, err := m.PutObject(context.Background(), &s3.PutObjectInput{
+			Bucket: aws.String("bucket"),
+			Key:    aws.String(key),
+			Body:   strings.NewReader(key),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err := Download(context.Background(), Options{
+		Client:        m,
+		SkipPreflight: true,
+		Bucket:        "bucket",
+		Source:        []string{"dist/**"},
+		Target:        "out",
+		StripPrefix:   "dist/",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, key := range map[string]string{
+		"out/index.html":    "dist/index.html",
+		"out/assets/app.js": "dist/assets/app.js",
+	} {
+		b, err := ioutil.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+		if err != nil {
+			t.Errorf("file %s not downloaded: %s", name, err)
+			continue
+		}
+		if string(b) != key {
+			t.Errorf("got %s content %q, want %q", name, b, key)
+		}
+	}
+	for _, name := range []string{"out/other.txt", "escaped", "../escaped"} {
+		if _, err := os.Stat(filepath.Join(dir, filepath.FromSlash(name))); err == nil {
+			t.Errorf("file %s downloaded", name)
+		}
+	}
+}
+
+func TestDownloadLeadingSlash(t *testing.T) {
+	dir := chdir(t, nil)
+
+	m := NewMemory("bucket")
+	for _, key := range []string{"/dist/index.html", "/dist/app.js", "/dist/app.js.map", "dist/bare.html"} {
+		_, err := m.PutObject(context.Background(), &s3.PutObjectInput{
+			Bucket: aws.String("bucket"),
+			Key:    aws.String(key),
+			Body:   strings.NewReader(key),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, source := range []string{"dist/**", "/dist/**"} {
+		out := filepath.Join(dir, "out"+strings.Replace(source, "/", "-", -1))
+		err := Download(context.Background(), Options{
+			Client:        m,
+			SkipPreflight: true,
+			Bucket:        "bucket",
+			Source:        []string{source},
+			Exclude:       []string{"**/*.map"},
+			Target:        out,
+			StripPrefix:   "dist/",
+			LeadingSlash:  true,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for name, key := range map[string]string{
+			"index.html": "/dist/index.html",
+			"app.js":     "/dist/app.js",
+		} {
+			b, err := ioutil.ReadFile(filepath.Join(out, name))
+			if err != nil {
+				t.Errorf("file %s of %s not downloaded: %s", name, source, err)
+				continue
+			}
+			if string(b) != key {
+				t.Errorf("got %s content %q, want %q", name, b, key)
+			}
+		}
+		for _, name := range []string{"app.js.map", "bare.html"} {
+			if _, err := os.Stat(filepath.Join(out, name)); err == nil {
+				t.Errorf("file %s of %s downloaded", name, source)
+			}
+		}
+	}
+}
+
+func TestResolveKey(t *testing.T) {
+	tests := []struct {
+		target, name, stripPrefix string
+		want                      string
+	}{
+		{"", "dist/index.html", "", "dist/index.html"},
+		{"", `dist\index.html`, "", "dist/index.html"},
+		{"", `dist\assets\app.js`, `dist\`, "assets/app.js"},
+		{"", `dist\assets\app.js`, "dist/", "assets/app.js"},
+		{"", "dist/assets/app.js", `dist\`, "assets/app.js"},
+		{"", `dist/assets\app.js`, `dist\assets/`, "app.js"},
+		{"site", "dist/index.html", "dist/", "site/index.html"},
+		{"/site/", "dist/index.html", "dist/", "site/index.html"},
+		{`site\v1`, `dist\index.html`, `dist\`, "site/v1/index.html"},
+		{`\site\v1\`, `dist\assets\app.js`, "dist/", "site/v1/assets/app.js"},
+		{"site/v1", "index.html", "", "site/v1/index.html"},
+	}
+	for _, tt := range tests {
+		got := resolveKey(tt.target, tt.name, tt.stripPrefix)
+		if got != tt.want {
+			t.Errorf("resolveKey(%q, %q, %q) = %q, want %q", tt.target, tt.name, tt.stripPrefix, got, tt.want)
+		}
+		if strings.Contains(got, `\`) {
+			t.Errorf("resolveKey(%q, %q, %q) = %q contains a backslash", tt.target, tt.name, tt.stripPrefix, got)
+		}
+	}
+}
+
+func TestRelPath(t *testing.T) {
+	tests := []struct {
+		name, stripPrefix string
+		want              string
+	}{
+		{"dist/index.html", "", "dist/index.html"},
+		{`dist\index.html`, "", "dist/index.html"},
+		{`dist\sub\index.html`, "dist/", "sub/index.html"},
+		{"dist/sub/index.html", `dist\`, "sub/index.html"},
+		{"other/index.html", "dist/", "other/index.html"},
+	}
+	for _, tt := range tests {
+		if got := relPath(tt.name, tt.stripPrefix); got != tt.want {
+			t.Errorf("relPath(%q, %q) = %q, want %q", tt.name, tt.stripPrefix, got, tt.want)
+		}
+	}
+}
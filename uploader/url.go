@@ -0,0 +1,72 @@
+package uploader
+
+import (
+	"context"
+	"net/url"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// publicURLs adds the public URLs of the uploaded files to the report. The
+// URLs only serve the files when the objects are publicly readable, e.g.
+// uploaded with the public-read ACL or allowed by the bucket policy.
+func (o *Options) publicURLs(ctx context.Context, report *Report) error {
+	for i, f := range report.Files {
+		u, err := o.publicURL(ctx, f.Key)
+		if err != nil {
+			return err
+		}
+		report.Files[i].PublicURL = u
+
+		log.WithFields(log.Fields{
+			"name":   f.Name,
+			"target": f.Key,
+			"url":    u,
+		}).Info("Public file URL")
+	}
+	return nil
+}
+
+// publicURL returns the URL of the object, resolved like the requests of the
+// client: virtual-hosted style unless path style is enabled, on the regional
+// AWS endpoint of the bucket or on the custom endpoint.
+func (o *Options) publicURL(ctx context.Context, key string) (string, error) {
+	params := s3.EndpointParameters{
+		Bucket:         aws.String(o.Bucket),
+		Region:         aws.String(o.Region),
+		ForcePathStyle: aws.Bool(o.PathStyle),
+	}
+	// the dual-stack and FIPS endpoints don't apply to custom endpoints.
+	if o.Endpoint != "" {
+		params.Endpoint = aws.String(baseEndpoint(o.Endpoint))
+	} else {
+		params.UseDualStack = aws.Bool(o.UseDualstack)
+		params.UseFIPS = aws.Bool(o.UseFIPS)
+	}
+
+	endpoint, err := s3.NewDefaultEndpointResolverV2().ResolveEndpoint(ctx, params)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"bucket":   o.Bucket,
+			"region":   o.Region,
+			"endpoint": o.Endpoint,
+			"error":    err,
+		}).Error("Could not resolve the bucket URL")
+		return "", err
+	}
+
+	return strings.TrimSuffix(endpoint.URI.String(), "/") + "/" + escapeKey(key), nil
+}
+
+// escapeKey is a helper function that escapes the segments of the key for
+// use in a URL path.
+func escapeKey(key string) string {
+	segments := strings.Split(key, "/")
+	for i, s := range segments {
+		segments[i] = url.PathEscape(s)
+	}
+	return strings.Join(segments, "/")
+}
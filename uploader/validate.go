@@ -0,0 +1,199 @@
+package uploader
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// regionPattern matches the regions of the known AWS partitions, e.g.
+// us-east-1, cn-north-1 or us-gov-west-1.
+var regionPattern = regexp.MustCompile(`^(us|eu|ap|sa|ca|me|af|il|mx|cn|us-gov|us-iso|us-isob|us-isof|eu-isoe|eusc-de)-[a-z]+-\d+$`)
+
+// encryptions lists the supported server-side encryption algorithms.
+var encryptions = []string{
+	string(types.ServerSideEncryptionAes256),
+	string(types.ServerSideEncryptionAwsKms),
+	string(types.ServerSideEncryptionAwsKmsDsse),
+}
+
+// checkSettings checks the setting values and combinations rejected by S3,
+// so misconfigured steps fail before uploading any file.
+func (o *Options) checkSettings() error {
+	// custom endpoints accept any region, e.g. minio.
+	if o.Region != "" && o.Endpoint == "" && !regionPattern.MatchString(o.Region) {
+		return fmt.Errorf("unsupported region %q, expected an AWS region like us-east-1", o.Region)
+	}
+	for i, d := range o.destinations() {
+		if d.Bucket == "" {
+			return fmt.Errorf("invalid destinations: destination %d: missing bucket", i+1)
+		}
+		if d.Region != "" && d.Endpoint == "" && !regionPattern.MatchString(d.Region) {
+			return fmt.Errorf("invalid destinations: destination %d: unsupported region %q", i+1, d.Region)
+		}
+	}
+
+	acls := []string{"none"}
+	for _, acl := range types.ObjectCannedACL("").Values() {
+		acls = append(acls, string(acl))
+	}
+	if err := oneOf("acl", o.Access, acls); err != nil {
+		return err
+	}
+	for i, m := range o.Mappings {
+		if err := oneOf("acl", m.Access, acls); err != nil {
+			return fmt.Errorf("invalid mappings: mapping %d: %s", i+1, err)
+		}
+	}
+	if o.grants() && o.Access != "" && o.Access != "private" && o.Access != "none" {
+		return fmt.Errorf("acl %q can't be combined with grants, remove the acl", o.Access)
+	}
+
+	var bucketACLs []string
+	for _, acl := range types.BucketCannedACL("").Values() {
+		bucketACLs = append(bucketACLs, string(acl))
+	}
+	if err := oneOf("bucket acl", o.BucketACL, bucketACLs); err != nil {
+		return err
+	}
+
+	if w := o.WebsiteConfig; w != nil {
+		switch {
+		case w.RedirectAllRequestsTo == "" && w.IndexDocument == "":
+			return errors.New("invalid website config: missing index_document or redirect_all_requests_to")
+		case w.RedirectAllRequestsTo != "" && (w.IndexDocument != "" || w.ErrorDocument != ""):
+			return errors.New("invalid website config: redirect_all_requests_to can't be combined with the documents")
+		case strings.Contains(w.RedirectAllRequestsTo, "://") && !strings.HasPrefix(w.RedirectAllRequestsTo, "http://") && !strings.HasPrefix(w.RedirectAllRequestsTo, "https://"):
+			return fmt.Errorf("invalid website config: unsupported protocol of redirect_all_requests_to %q, expected http or https", w.RedirectAllRequestsTo)
+		case strings.Contains(w.IndexDocument, "/"):
+			return fmt.Errorf("invalid website config: index_document %q can't contain a slash", w.IndexDocument)
+		}
+	}
+	for i, r := range o.CORS {
+		if len(r.AllowedOrigins) == 0 {
+			return fmt.Errorf("invalid cors: rule %d: missing allowed_origins", i+1)
+		}
+		if len(r.AllowedMethods) == 0 {
+			return fmt.Errorf("invalid cors: rule %d: missing allowed_methods", i+1)
+		}
+		for _, method := range r.AllowedMethods {
+			if err := oneOf("method", method, corsMethods); err != nil {
+				return fmt.Errorf("invalid cors: rule %d: %s", i+1, err)
+			}
+		}
+	}
+
+	if o.Sitemap && !o.Website {
+		return errors.New("sitemap requires the website mode, enable website")
+	}
+	if o.Sitemap && !strings.HasPrefix(o.BaseURL, "http://") && !strings.HasPrefix(o.BaseURL, "https://") {
+		return fmt.Errorf("sitemap requires the base url of the site like https://example.com, got %q", o.BaseURL)
+	}
+
+	if o.MaxSize > 0 && o.MinSize > o.MaxSize {
+		return fmt.Errorf("min size %s exceeds the max size %s", formatSize(o.MinSize), formatSize(o.MaxSize))
+	}
+
+	if err := oneOf("overwrite", o.Overwrite, overwritePolicies); err != nil {
+		return err
+	}
+	if o.OnlyChanged && o.Overwrite != "" && o.Overwrite != overwriteIfDifferent {
+		return fmt.Errorf("only changed can't be combined with overwrite %s, remove only_changed", o.Overwrite)
+	}
+
+	if o.Atomic && o.Immutable {
+		return errors.New("atomic can't be combined with immutable, the staged files are copied to the target")
+	}
+
+	if o.Atomic && o.Rollback {
+		return errors.New("atomic can't be combined with rollback, the target isn't updated when an upload fails")
+	}
+
+	if o.RemoteManifest && o.StateFile != "" {
+		return errors.New("remote manifest can't be combined with a state file, remove the state_file")
+	}
+
+	if err := oneOf("integrity", o.Integrity, integrityAlgorithms); err != nil {
+		return err
+	}
+
+	if o.Presign && o.Manifest == "" {
+		return errors.New("presigned URLs are only written to the manifest, set the manifest")
+	}
+	if o.PresignExpires > maxPresignExpires {
+		return fmt.Errorf("presign expires %s exceeds the maximum of 7 days", o.PresignExpires)
+	}
+
+	var tiers []string
+	for _, tier := range types.Tier("").Values() {
+		tiers = append(tiers, string(tier))
+	}
+	if err := oneOf("restore tier", o.RestoreTier, tiers); err != nil {
+		return err
+	}
+
+	var classes []string
+	for _, class := range types.StorageClass("").Values() {
+		classes = append(classes, string(class))
+	}
+	for pattern, class := range o.StorageClass {
+		if err := oneOf("storage class", class, classes); err != nil {
+			return fmt.Errorf("storage class of %s: %s", pattern, err)
+		}
+	}
+
+	var modes []string
+	for _, mode := range types.ObjectLockMode("").Values() {
+		modes = append(modes, string(mode))
+	}
+	if err := oneOf("object lock mode", o.ObjectLockMode, modes); err != nil {
+		return err
+	}
+	if o.ObjectLockMode != "" && !o.objectLock() {
+		return fmt.Errorf("object lock mode %s requires a retain until date", o.ObjectLockMode)
+	}
+	if o.objectLock() && o.ObjectLockMode == "" {
+		return errors.New("object lock retain until date requires an object lock mode")
+	}
+	if o.objectLock() && !o.ObjectLockRetainUntil.After(time.Now()) {
+		return fmt.Errorf("object lock retain until date %s is in the past", o.ObjectLockRetainUntil.Format(time.RFC3339))
+	}
+
+	if err := oneOf("encryption", o.Encryption, encryptions); err != nil {
+		return err
+	}
+	if o.KMSKeyID != "" && !strings.HasPrefix(o.Encryption, "aws:kms") {
+		return fmt.Errorf("kms key id requires aws:kms encryption, not %s", o.Encryption)
+	}
+	if o.SSECustomerKey != "" && o.Encryption != "" {
+		return fmt.Errorf("sse customer key can't be combined with %s encryption", o.Encryption)
+	}
+
+	// files marked as compressed by the build are never compressed again.
+	if o.Compress {
+		for pattern := range o.ContentEncoding {
+			if pattern == "*" || pattern == "**" || pattern == "**/*" {
+				return fmt.Errorf("compress has no effect, content encoding pattern %q marks all files as compressed", pattern)
+			}
+		}
+	}
+	return nil
+}
+
+// oneOf is a helper function that checks the value is empty or one of the
+// allowed values.
+func oneOf(setting, value string, allowed []string) error {
+	if value == "" {
+		return nil
+	}
+	for _, v := range allowed {
+		if value == v {
+			return nil
+		}
+	}
+	return fmt.Errorf("unsupported %s %q, expected one of %s", setting, value, strings.Join(allowed, ", "))
+}
@@ -0,0 +1,119 @@
+package uploader
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"hash"
+	"io"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// verify checks the uploaded object against the local file, comparing the
+// object size, ETag and additional checksum with the uploaded content. When
+// compress is set the content is compressed before comparing.
+func (o *Options) verify(ctx context.Context, client S3API, match, target, compress string) error {
+	input := &s3.HeadObjectInput{
+		Bucket: aws.String(o.Bucket),
+		Key:    aws.String(target),
+	}
+	if o.SSECustomerKey != "" {
+		input.SSECustomerKey, input.SSECustomerKeyMD5 = sseCustomerKey(o.SSECustomerKey)
+		input.SSECustomerAlgorithm = aws.String(o.SSECustomerAlgorithm)
+	}
+	if o.ChecksumAlgorithm != "" {
+		input.ChecksumMode = types.ChecksumModeEnabled
+	}
+
+	head, err := client.HeadObject(ctx, input)
+	if err != nil {
+		return err
+	}
+
+	r, err := openContent(match, compress)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	var (
+		size              = &counter{}
+		w       io.Writer = size
+		summer  hash.Hash
+		checked string
+	)
+	if o.ChecksumAlgorithm != "" {
+		checked = remoteChecksum(head, o.ChecksumAlgorithm)
+		// multipart objects carry a checksum of the part checksums, which
+		// can't be compared with the checksum of the content.
+		if checked != "" && !strings.Contains(checked, "-") {
+			if summer, err = newChecksum(o.ChecksumAlgorithm); err != nil {
+				return err
+			}
+			w = io.MultiWriter(size, summer)
+		}
+	}
+
+	single, _, err := etags(io.TeeReader(r, w), o.partSize())
+	if err != nil {
+		return err
+	}
+
+	if remote := aws.ToInt64(head.ContentLength); remote != size.n {
+		return fmt.Errorf("size of %s is %d, expected %d", target, remote, size.n)
+	}
+
+	if etag := strings.Trim(aws.ToString(head.ETag), `"`); isContentMD5(head) && etag != single {
+		return fmt.Errorf("etag of %s is %s, expected %s", target, etag, single)
+	}
+
+	if summer != nil {
+		if sum := base64.StdEncoding.EncodeToString(summer.Sum(nil)); sum != checked {
+			return fmt.Errorf("%s checksum of %s is %s, expected %s", o.ChecksumAlgorithm, target, checked, sum)
+		}
+	}
+	return nil
+}
+
+// isContentMD5 is a helper function that reports whether the ETag of the
+// object is the MD5 of the content. The ETag of objects encrypted with SSE-C
+// or SSE-KMS, also by the bucket default encryption, is not, and neither is
+// the ETag of multipart objects, which depends on the part size.
+func isContentMD5(head *s3.HeadObjectOutput) bool {
+	switch head.ServerSideEncryption {
+	case "", types.ServerSideEncryptionAes256:
+	default:
+		return false
+	}
+	return aws.ToString(head.SSECustomerAlgorithm) == "" && !strings.Contains(aws.ToString(head.ETag), "-")
+}
+
+// remoteChecksum is a helper function that returns the additional checksum
+// of the object for the given algorithm.
+func remoteChecksum(head *s3.HeadObjectOutput, algorithm string) string {
+	switch types.ChecksumAlgorithm(algorithm) {
+	case types.ChecksumAlgorithmCrc32:
+		return aws.ToString(head.ChecksumCRC32)
+	case types.ChecksumAlgorithmCrc32c:
+		return aws.ToString(head.ChecksumCRC32C)
+	case types.ChecksumAlgorithmSha1:
+		return aws.ToString(head.ChecksumSHA1)
+	case types.ChecksumAlgorithmSha256:
+		return aws.ToString(head.ChecksumSHA256)
+	}
+	return ""
+}
+
+// counter is an io.Writer counting the bytes written.
+type counter struct {
+	n int64
+}
+
+func (c *counter) Write(b []byte) (int, error) {
+	c.n += int64(len(b))
+	return len(b), nil
+}
@@ -0,0 +1,29 @@
+package uploader
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+func TestIsContentMD5(t *testing.T) {
+	tests := []struct {
+		name string
+		head *s3.HeadObjectOutput
+		want bool
+	}{
+		{"unencrypted", &s3.HeadObjectOutput{ETag: aws.String(`"d41d8cd98f00b204e9800998ecf8427e"`)}, true},
+		{"sse-s3", &s3.HeadObjectOutput{ETag: aws.String(`"d41d8cd98f00b204e9800998ecf8427e"`), ServerSideEncryption: types.ServerSideEncryptionAes256}, true},
+		{"sse-kms", &s3.HeadObjectOutput{ETag: aws.String(`"0a1b2c"`), ServerSideEncryption: types.ServerSideEncryptionAwsKms}, false},
+		{"dsse-kms", &s3.HeadObjectOutput{ETag: aws.String(`"0a1b2c"`), ServerSideEncryption: types.ServerSideEncryptionAwsKmsDsse}, false},
+		{"sse-c", &s3.HeadObjectOutput{ETag: aws.String(`"0a1b2c"`), ServerSideEncryption: types.ServerSideEncryptionAes256, SSECustomerAlgorithm: aws.String("AES256")}, false},
+		{"multipart", &s3.HeadObjectOutput{ETag: aws.String(`"d41d8cd98f00b204e9800998ecf8427e-3"`)}, false},
+	}
+	for _, tt := range tests {
+		if got := isContentMD5(tt.head); got != tt.want {
+			t.Errorf("isContentMD5 of %s object = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package uploader
+
+import (
+	"path"
+	"strings"
+)
+
+// websiteCacheControl is a helper function that returns the default
+// Cache-Control header of the website file: pages are revalidated on every
+// request, assets with a content hash in the name are cached forever.
+func websiteCacheControl(name string) string {
+	switch {
+	case isPage(name):
+		return "no-cache"
+	case isHashed(name):
+		return immutableCacheControl
+	}
+	return ""
+}
+
+// isPage is a helper function that reports whether the file is an HTML page.
+func isPage(name string) bool {
+	switch strings.ToLower(path.Ext(trimCompressedExt(name))) {
+	case ".html", ".htm":
+		return true
+	}
+	return false
+}
+
+// isHashed is a helper function that reports whether the file name contains
+// a content hash added by the bundler, e.g. app.3f2a9c1b.js or
+// index-B7xQ2kLm.css.
+func isHashed(name string) bool {
+	base := path.Base(trimCompressedExt(name))
+	parts := strings.FieldsFunc(strings.TrimSuffix(base, path.Ext(base)), func(r rune) bool {
+		return r == '.' || r == '-'
+	})
+	// the first part is the name of the file itself.
+	for i := 1; i < len(parts); i++ {
+		if isHash(parts[i]) {
+			return true
+		}
+	}
+	return false
+}
+
+// isHash is a helper function that reports whether the string looks like a
+// content hash, at least 8 letters and digits mixing both.
+func isHash(s string) bool {
+	if len(s) < 8 {
+		return false
+	}
+	var letters, digits bool
+	for _, r := range s {
+		switch {
+		case r >= '0' && r <= '9':
+			digits = true
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r == '_':
+			letters = true
+		default:
+			return false
+		}
+	}
+	return letters && digits
+}
+
+// uploadOrder returns the batch the file is uploaded in: the assets first,
+// then the website pages, then the files matching the upload last patterns.
+func (o *Options) uploadOrder(rel string) int {
+	for _, pattern := range o.UploadLast {
+		if matchPattern(pattern, rel) {
+			return 2
+		}
+	}
+	if o.Website && isPage(rel) {
+		return 1
+	}
+	return 0
+}
+
+// batches splits the files into the batches uploaded one after another, each
+// keeping the order of the files.
+func (o *Options) batches(files []string) [][]string {
+	batches := make([][]string, 3)
+	for _, name := range files {
+		i := o.uploadOrder(relPath(name, o.StripPrefix))
+		batches[i] = append(batches[i], name)
+	}
+	return batches
+}
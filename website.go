@@ -0,0 +1,40 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/drone-plugins/drone-s3/uploader"
+)
+
+// parseWebsite is a helper function that parses the website configuration
+// provided as a JSON object, e.g. {"index_document": "index.html"}
+func parseWebsite(value string) (*uploader.WebsiteConfig, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, nil
+	}
+
+	website := &uploader.WebsiteConfig{}
+	if err := json.Unmarshal([]byte(value), website); err != nil {
+		return nil, fmt.Errorf("invalid website config: %s", err)
+	}
+	return website, nil
+}
+
+// parseCORS is a helper function that parses the CORS rules provided as a
+// JSON list of objects, e.g. [{"allowed_origins": ["*"], "allowed_methods":
+// ["GET"]}]
+func parseCORS(value string) ([]uploader.CORSRule, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, nil
+	}
+
+	var rules []uploader.CORSRule
+	if err := json.Unmarshal([]byte(value), &rules); err != nil {
+		return nil, fmt.Errorf("invalid cors: %s", err)
+	}
+	return rules, nil
+}